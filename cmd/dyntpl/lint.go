@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/koykov/dyntpl"
+)
+
+// Check templates in given files and directories.
+//
+// Templates parses in collect mode to report all errors at once. If list of variables is given, templates validates
+// as well, see dyntpl.Validate().
+func cmdLint(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	keepFmt := fs.Bool("keep-fmt", false, "keep formatting (new lines and tabulations) of the template")
+	ext := fs.String("ext", ".tpl", "extension of template files in directories")
+	vars := fs.String("vars", "", "comma separated list of variables passed to templates, enables validation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	files, err := findFiles(fs.Args(), *ext)
+	if err != nil {
+		return err
+	}
+	var schema []dyntpl.SchemaVar
+	if len(*vars) > 0 {
+		for _, name := range strings.Split(*vars, ",") {
+			schema = append(schema, dyntpl.SchemaVar{Name: strings.TrimSpace(name)})
+		}
+	}
+
+	var failed bool
+	for _, file := range files {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		tree, err := dyntpl.ParseAll(raw, *keepFmt)
+		if err == nil && schema != nil {
+			err = dyntpl.Validate(tree, schema...)
+		}
+		if err != nil {
+			writeErrors(w, file, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errProblems
+	}
+	return nil
+}
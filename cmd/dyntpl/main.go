@@ -0,0 +1,113 @@
+// Command dyntpl is a tool to debug templates: parse and dump the tree, lint templates and render them using JSON
+// data.
+//
+// Usage:
+//
+//	dyntpl parse [-keep-fmt] file
+//	dyntpl lint [-keep-fmt] [-ext .tpl] [-vars name,...] path...
+//	dyntpl render [-keep-fmt] [-ext .tpl] [-dir path] [-data file] file
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koykov/dyntpl"
+)
+
+const usage = `dyntpl is a tool to debug templates.
+
+Usage:
+	dyntpl <command> [flags] [arguments]
+
+Commands:
+	parse   parse the template and print the tree
+	lint    check templates (files or directories) for errors
+	render  render the template using JSON data
+
+Run "dyntpl <command> -h" to see flags of the command.
+`
+
+var (
+	errUsage    = errors.New("wrong usage, see dyntpl -h")
+	errProblems = errors.New("problems found")
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "parse":
+		err = cmdParse(os.Stdout, os.Args[2:])
+	case "lint":
+		err = cmdLint(os.Stdout, os.Args[2:])
+	case "render":
+		err = cmdRender(os.Stdout, os.Stderr, os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		err = errUsage
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Write errors of the template file in "file:line:column: code: message 'construct'" format.
+func writeErrors(w io.Writer, file string, err error) {
+	var errs dyntpl.ParseErrors
+	if !errors.As(err, &errs) {
+		var perr *dyntpl.ParseError
+		if !errors.As(err, &perr) {
+			fmt.Fprintf(w, "%s: %s\n", file, err)
+			return
+		}
+		errs = dyntpl.ParseErrors{perr}
+	}
+	for _, e := range errs {
+		fmt.Fprintf(w, "%s:%d:%d: %s: %s '%s'\n", file, e.Line, e.Column, e.Code, e.Err, e.Construct)
+	}
+}
+
+// Make template ID using path relative to the root directory without extension.
+func tplID(root, file string) string {
+	id := file
+	if len(root) > 0 {
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			id = rel
+		}
+	}
+	return filepath.ToSlash(strings.TrimSuffix(id, filepath.Ext(id)))
+}
+
+// Find template files in the list of files and directories.
+func findFiles(paths []string, ext string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if file == path || filepath.Ext(file) == ext {
+				// Files given explicitly checks independent of extension.
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/koykov/dyntpl"
+)
+
+func TestParse(t *testing.T) {
+	var buf bytes.Buffer
+	if err := cmdParse(&buf, []string{"testdata/render/inc/row.tpl"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := ioutil.ReadFile("testdata/render/inc/row.tpl")
+	tree, _ := dyntpl.Parse(raw, false)
+	if !bytes.Equal(buf.Bytes(), tree.HumanReadable()) {
+		t.Errorf("parse output mismatch\nexp: %s\ngot: %s", tree.HumanReadable(), buf.String())
+	}
+}
+
+func TestLint(t *testing.T) {
+	var buf bytes.Buffer
+	if err := cmdLint(&buf, []string{"testdata/render"}); err != nil {
+		t.Errorf("lint of valid templates failed: %s\n%s", err, buf.String())
+	}
+
+	buf.Reset()
+	if err := cmdLint(&buf, []string{"testdata/lint"}); err != errProblems {
+		t.Errorf("lint error mismatch\nexp: %s\ngot: %v", errProblems, err)
+	}
+	exp := "testdata/lint/bad.tpl:1:1: unbalanced-ctl: control structure isn't closed '{% if a %}'\n" +
+		"testdata/lint/bad.tpl:2:2: unknown-mod: modifier not found '{%= b|nope() %}'\n" +
+		"testdata/lint/bad.tpl:3:1: unbalanced-ctl: end of control structure that wasn't opened '{% endfor %}'\n"
+	if buf.String() != exp {
+		t.Errorf("lint output mismatch\nexp: %s\ngot: %s", exp, buf.String())
+	}
+}
+
+func TestRender(t *testing.T) {
+	var buf, ebuf bytes.Buffer
+	err := cmdRender(&buf, &ebuf, []string{"-dir", "testdata/render", "-data", "testdata/render/data.json",
+		"testdata/render/page.tpl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `<h1>Users</h1><ul><li>John (admin)</li><li>Ann</li></ul>many`
+	if buf.String() != exp {
+		t.Errorf("render output mismatch\nexp: %s\ngot: %s", exp, buf.String())
+	}
+
+	buf.Reset()
+	if err = cmdRender(&buf, &ebuf, []string{"testdata/broken.tpl"}); err != errProblems {
+		t.Errorf("render error mismatch\nexp: %s\ngot: %v", errProblems, err)
+	}
+	if buf.Len() > 0 || !bytes.HasPrefix(ebuf.Bytes(), []byte("testdata/broken.tpl:1:5: ")) {
+		t.Errorf("render errors must be written apart from output\nout: %s\nerr: %s", buf.String(), ebuf.String())
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+
+	"github.com/koykov/dyntpl"
+)
+
+// Parse the template and print human readable view of the tree.
+func cmdParse(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	keepFmt := fs.Bool("keep-fmt", false, "keep formatting (new lines and tabulations) of the template")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	file := fs.Arg(0)
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	tree, err := dyntpl.ParseAll(raw, *keepFmt)
+	if err != nil {
+		writeErrors(w, file, err)
+		return errProblems
+	}
+	_, err = w.Write(tree.HumanReadable())
+	return err
+}
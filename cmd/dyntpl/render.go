@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/koykov/dyntpl"
+)
+
+var errDataObject = errors.New("data must be an object")
+
+// Render the template using data from JSON file.
+//
+// Each key of the top-level data object passes to the template as a variable. Templates from the directory registers
+// to use them in include and extends structures, their IDs are relative paths without extension. Errors of templates
+// writes to ew to keep them apart from the output.
+func cmdRender(w, ew io.Writer, args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	keepFmt := fs.Bool("keep-fmt", false, "keep formatting (new lines and tabulations) of the template")
+	ext := fs.String("ext", ".tpl", "extension of template files in directory")
+	dir := fs.String("dir", "", "directory of templates to include or extend")
+	data := fs.String("data", "", "JSON file with data")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	file := fs.Arg(0)
+
+	root := *dir
+	if len(root) == 0 {
+		root = filepath.Dir(file)
+	} else if err := dyntpl.LoadDir(root, "*"+*ext, *keepFmt); err != nil {
+		var errs dyntpl.LoadErrors
+		if !errors.As(err, &errs) {
+			return err
+		}
+		for _, e := range errs {
+			writeErrors(ew, filepath.Join(root, e.File), e.Err)
+		}
+		return errProblems
+	}
+	if err := register(ew, root, file, *keepFmt); err != nil {
+		return err
+	}
+
+	ctx := dyntpl.AcquireCtx()
+	defer dyntpl.ReleaseCtx(ctx)
+	if len(*data) > 0 {
+		vars, err := readData(*data)
+		if err != nil {
+			return err
+		}
+		for k, v := range vars {
+			ctx.SetMap(k, v)
+		}
+	}
+	return dyntpl.RenderTo(w, tplID(root, file), ctx)
+}
+
+// Parse the template file and register it, errors of the template writes to ew.
+func register(ew io.Writer, root, file string, keepFmt bool) error {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	tree, err := dyntpl.Parse(raw, keepFmt)
+	if err != nil {
+		writeErrors(ew, file, err)
+		return errProblems
+	}
+	dyntpl.RegisterTpl(tplID(root, file), tree)
+	return nil
+}
+
+// Read JSON data file.
+func readData(file string) (map[string]interface{}, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	vars, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, errDataObject
+	}
+	return vars, nil
+}
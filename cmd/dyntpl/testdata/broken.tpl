@@ -0,0 +1 @@
+<ul>{% for %}<li>{%= v %}</li>{% endfor %}</ul>
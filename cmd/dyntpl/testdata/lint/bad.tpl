@@ -0,0 +1,3 @@
+{% if a %}
+	{%= b|nope() %}
+{% endfor %}
@@ -0,0 +1 @@
+<li>{%= u.name %}{% if u.admin == true %} (admin){% endif %}</li>
@@ -0,0 +1,7 @@
+<h1>{%= title %}</h1>
+<ul>
+{% for _, u := range users %}
+	{% include inc/row %}
+{% endfor %}
+</ul>
+{% if count > 1 %}many{% else %}few{% endif %}
@@ -3,19 +3,29 @@ package dyntpl
 // Condition helper func signature.
 type CondFn func(ctx *Ctx, args []interface{}) bool
 
-var (
-	// Registry of condition helpers.
-	condRegistry = map[string]CondFn{}
-)
-
-// Register new condition helper.
+// Register new condition helper in the default set.
 func RegisterCondFn(name string, cond CondFn) {
-	condRegistry[name] = cond
+	defaultSet.RegisterCondFn(name, cond)
 }
 
-// Get condition helper from the registry.
+// Get condition helper from the default set.
 func GetCondFn(name string) *CondFn {
-	if fn, ok := condRegistry[name]; ok {
+	return defaultSet.GetCondFn(name)
+}
+
+// Register new condition helper in the set.
+func (s *Set) RegisterCondFn(name string, cond CondFn) {
+	s.rmux.Lock()
+	s.cond[name] = cond
+	s.rmux.Unlock()
+}
+
+// Get condition helper from the set.
+func (s *Set) GetCondFn(name string) *CondFn {
+	s.rmux.RLock()
+	fn, ok := s.cond[name]
+	s.rmux.RUnlock()
+	if ok {
 		return &fn
 	}
 	return nil
@@ -0,0 +1,191 @@
+package dyntpl
+
+import (
+	"bytes"
+
+	"github.com/koykov/x2bytes"
+)
+
+// Type of the condition expression node.
+type exprType int
+
+const (
+	// Known types of expression nodes.
+	exprCmp exprType = iota
+	exprHlp
+	exprVar
+	exprNot
+	exprAnd
+	exprOr
+)
+
+// Condition expression is a tree representation of complex conditions, like:
+// {% if user.Status > 10 && (lenGt0(user.Name) || !user.Finance.AllowBuy) %}...{% endif %}
+type condExpr struct {
+	typ exprType
+
+	// Comparison operands, see exprCmp. Left operand is used by exprVar as well.
+	l, r   []byte
+	lp, rp *vpath
+	sl, sr bool
+	op     Op
+
+	// Condition helper, its arguments and function resolved at parse time, see exprHlp.
+	hlp    []byte
+	hlpArg []*arg
+	hlpFn  *CondFn
+
+	// Operands of logic operations. Unary negation uses only left operand.
+	left, right *condExpr
+}
+
+var (
+	// Right side of implicit comparison of standalone variables.
+	exprTrue = []byte("true")
+)
+
+// Split paths of variables in the expression tree.
+func (e *condExpr) split() {
+	if e == nil {
+		return
+	}
+	if !e.sl {
+		e.lp = newPath(e.l)
+	}
+	if !e.sr && len(e.r) > 0 {
+		e.rp = newPath(e.r)
+	}
+	splitArgs(e.hlpArg)
+	e.left.split()
+	e.right.split()
+}
+
+// Evaluate the expression using given context.
+//
+// Logic operations are short-circuited, so right operand will not be evaluated if left one is enough to get the result.
+func (e *condExpr) eval(ctx *Ctx) (r bool, err error) {
+	switch e.typ {
+	case exprAnd:
+		if r, err = e.left.eval(ctx); err != nil || !r {
+			return
+		}
+		return e.right.eval(ctx)
+	case exprOr:
+		if r, err = e.left.eval(ctx); err != nil || r {
+			return
+		}
+		return e.right.eval(ctx)
+	case exprNot:
+		r, err = e.left.eval(ctx)
+		r = !r
+		return
+	case exprHlp:
+		fn := e.hlpFn
+		if fn == nil {
+			err = ErrCondHlpNotFound
+			return
+		}
+		// Prepare arguments list.
+		ctx.bufA = ctx.bufA[:0]
+		for _, a := range e.hlpArg {
+			if a.static {
+				ctx.bufA = append(ctx.bufA, &a.val)
+			} else {
+				val := ctx.get(a.path)
+				ctx.bufA = append(ctx.bufA, val)
+			}
+		}
+		// Call condition helper func.
+		r = (*fn)(ctx, ctx.bufA)
+	case exprVar:
+		// Standalone variable, check it as a boolean.
+		if e.sl {
+			err = ErrSenselessCond
+			return
+		}
+		r = ctx.cmp(e.lp, OpEq, exprTrue)
+	default:
+		if e.sl && e.sr {
+			// It's senseless to compare two static values.
+			err = ErrSenselessCond
+			return
+		}
+		if e.sr {
+			r = ctx.cmp(e.lp, e.op, e.r)
+		} else if e.sl {
+			r = ctx.cmp(e.rp, e.op.Swap(), e.l)
+		} else {
+			ctx.get(e.rp)
+			if ctx.Err == nil {
+				ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, ctx.bufX)
+				if err != nil {
+					return
+				}
+				r = ctx.cmp(e.lp, e.op, ctx.Buf)
+			}
+		}
+	}
+	if ctx.Err != nil {
+		err = ctx.Err
+	}
+	return
+}
+
+// Write human readable view of the expression to the buffer.
+func (e *condExpr) write(buf *bytes.Buffer) {
+	switch e.typ {
+	case exprAnd, exprOr:
+		e.writeOperand(buf, e.left)
+		if e.typ == exprAnd {
+			buf.WriteString(" && ")
+		} else {
+			buf.WriteString(" || ")
+		}
+		e.writeOperand(buf, e.right)
+	case exprNot:
+		buf.WriteByte('!')
+		e.writeOperand(buf, e.left)
+	case exprHlp:
+		buf.Write(e.hlp)
+		buf.WriteByte('(')
+		for i, a := range e.hlpArg {
+			if i > 0 {
+				buf.WriteByte(',')
+				buf.WriteByte(' ')
+			}
+			if a.static {
+				buf.WriteByte('"')
+				buf.Write(a.val)
+				buf.WriteByte('"')
+			} else {
+				buf.Write(a.val)
+			}
+		}
+		buf.WriteByte(')')
+	case exprVar:
+		buf.Write(e.l)
+	default:
+		buf.Write(e.l)
+		buf.WriteByte(' ')
+		buf.WriteString(e.op.String())
+		buf.WriteByte(' ')
+		buf.Write(e.r)
+	}
+}
+
+// Write operand of logic operation, wrap it with parentheses if precedence requires that.
+func (e *condExpr) writeOperand(buf *bytes.Buffer, operand *condExpr) {
+	var wrap bool
+	if e.typ == exprNot {
+		wrap = operand.typ == exprCmp || operand.typ == exprAnd || operand.typ == exprOr
+	} else {
+		wrap = operand.typ == exprOr && e.typ == exprAnd
+	}
+	if wrap {
+		buf.WriteByte('(')
+	}
+	operand.write(buf)
+	if wrap {
+		buf.WriteByte(')')
+	}
+}
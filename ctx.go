@@ -1,7 +1,7 @@
 package dyntpl
 
 import (
-	"bytes"
+	"encoding/json"
 	"io"
 	"strconv"
 
@@ -17,22 +17,35 @@ type Ctx struct {
 	// List of context variables and list len.
 	vars []ctxVar
 	ln   int
-	// Check square brackets flag.
-	chQB bool
 	// Check json quote/escape/encode flags.
 	chJQ, chHE, chUE bool
 	// Internal buffers.
 	buf  []byte
 	bufS []string
+	bufP []string
 	bufI int
 	bufX interface{}
 	bufA []interface{}
+	// Buffers to evaluate index expressions in paths, see Ctx.splitPath().
+	bufQ  []byte
+	bufQO []int
+	bufQD int
+	// Path to parse on the fly, see Ctx.Get().
+	bufVP vpath
+	// Keys of maps to sort in loops of built-in inspectors, see MapInspector.Loop() and ReflectInspector.Loop().
+	bufMK mapKeys
+	bufRK reflectKeys
 	// Range loop helper.
 	rl *RangeLoop
+	// List of variables hidden by local scopes.
+	hid []ctxHidden
 
-	// List of internal byte writers to process include expressions.
-	w  []bytes.Buffer
-	wl int
+	// Inheritance chain of templates (from the most derived), chain offset of current render and current block.
+	ext  []*Tpl
+	extO int
+	blk  ctxBlock
+	// Generation of templates registry the render started with, see Set.RenderTo().
+	gen *tplGen
 
 	// External buffers to use in modifier and condition helpers.
 	Buf, Buf1, Buf2 bytealg.ChainBuf
@@ -54,17 +67,13 @@ type ctxVar struct {
 	// Special case: var is counter.
 	cntrF bool
 	cntr  int
+	// Special case: var is an alias of other variable's path, see Ctx.bindVar().
+	pfx  []string
+	pfxB []byte
 
 	ins inspector.Inspector
 }
 
-var (
-	// Byte constants.
-	qbL = []byte("[")
-	qbR = []byte("]")
-	dot = []byte(".")
-)
-
 // Make new context object.
 func NewCtx() *Ctx {
 	ctx := Ctx{
@@ -80,13 +89,18 @@ func NewCtx() *Ctx {
 }
 
 // Set the variable to context.
-// Inspector ins should be correspond to variable val.
+// Inspector ins should be correspond to variable val. Nil inspector means that variable will be inspected using
+// reflection, see ReflectInspector.
 func (c *Ctx) Set(key string, val interface{}, ins inspector.Inspector) {
+	if ins == nil {
+		ins = reflectIns
+	}
 	for i := 0; i < c.ln; i++ {
 		if c.vars[i].key == key {
 			// Update existing variable.
 			c.vars[i].val = val
 			c.vars[i].ins = ins
+			c.vars[i].pfx = c.vars[i].pfx[:0]
 			return
 		}
 	}
@@ -96,6 +110,7 @@ func (c *Ctx) Set(key string, val interface{}, ins inspector.Inspector) {
 		c.vars[c.ln].key = key
 		c.vars[c.ln].val = val
 		c.vars[c.ln].ins = ins
+		c.vars[c.ln].pfx = c.vars[c.ln].pfx[:0]
 	} else {
 		// Extend the variable list with new one.
 		c.vars = append(c.vars, ctxVar{
@@ -118,6 +133,25 @@ func (c *Ctx) SetStatic(key string, val interface{}) {
 	c.Set(key, val, ins)
 }
 
+// Set plain Go map, slice or any data decoded from JSON as variable.
+//
+// Variable is inspected by built-in MapInspector, so generated inspector isn't required.
+func (c *Ctx) SetMap(key string, val interface{}) {
+	c.Set(key, val, mapIns)
+}
+
+// Decode JSON document and set it as variable.
+//
+// See Ctx.SetMap().
+func (c *Ctx) SetJSON(key string, data []byte) error {
+	var val interface{}
+	if err := json.Unmarshal(data, &val); err != nil {
+		return err
+	}
+	c.SetMap(key, val)
+	return nil
+}
+
 // Set bytes as static variable.
 //
 // See Ctx.Set().
@@ -132,6 +166,7 @@ func (c *Ctx) SetBytes(key string, val []byte) {
 		if c.vars[i].key == key {
 			c.vars[i].buf = append(c.vars[i].buf[:0], val...)
 			c.vars[i].ins = ins
+			c.vars[i].pfx = c.vars[i].pfx[:0]
 			return
 		}
 	}
@@ -139,6 +174,7 @@ func (c *Ctx) SetBytes(key string, val []byte) {
 		c.vars[c.ln].key = key
 		c.vars[c.ln].buf = append(c.vars[c.ln].buf[:0], val...)
 		c.vars[c.ln].ins = ins
+		c.vars[c.ln].pfx = c.vars[c.ln].pfx[:0]
 	} else {
 		v := ctxVar{
 			key: key,
@@ -167,6 +203,7 @@ func (c *Ctx) setCntr(key string, val int) {
 			c.vars[i].ins = ins
 			c.vars[i].val = nil
 			c.vars[i].buf = c.vars[i].buf[:0]
+			c.vars[i].pfx = c.vars[i].pfx[:0]
 			return
 		}
 	}
@@ -177,6 +214,7 @@ func (c *Ctx) setCntr(key string, val int) {
 		c.vars[c.ln].ins = ins
 		c.vars[c.ln].val = nil
 		c.vars[c.ln].buf = c.vars[c.ln].buf[:0]
+		c.vars[c.ln].pfx = c.vars[c.ln].pfx[:0]
 	} else {
 		v := ctxVar{
 			key:   key,
@@ -197,7 +235,7 @@ func (c *Ctx) setCntr(key string, val int) {
 // * user.Bio.Birthday
 // * staticVar
 func (c *Ctx) Get(path string) interface{} {
-	return c.get(fastconv.S2B(path))
+	return c.get(c.bufVP.parse(fastconv.S2B(path)))
 }
 
 // Reset the context.
@@ -208,23 +246,31 @@ func (c *Ctx) Reset() {
 		c.vars[i].cntrF = false
 		c.vars[i].val = nil
 		c.vars[i].buf = c.vars[i].buf[:0]
+		c.vars[i].pfx = c.vars[i].pfx[:0]
 	}
 	c.ln = 0
-
-	for i := 0; i < c.wl; i++ {
-		c.w[i].Reset()
+	for i := 0; i < len(c.hid); i++ {
+		c.vars[c.hid[i].idx].key = c.hid[i].key
 	}
-	c.wl = 0
+	c.hid = c.hid[:0]
+
+	c.ext, c.extO = c.ext[:0], 0
+	c.blk = ctxBlock{}
+	c.gen = nil
 
 	c.Err = nil
 	c.bufX = nil
-	c.chQB, c.chJQ, c.chHE, c.chUE = false, false, false, false
+	c.chJQ, c.chHE, c.chUE = false, false, false
 	c.bufS = c.bufS[:0]
+	c.bufP = c.bufP[:0]
+	c.bufQ, c.bufQO, c.bufQD = c.bufQ[:0], c.bufQO[:0], 0
 	c.Buf.Reset()
 	c.Buf1.Reset()
 	c.Buf2.Reset()
 	c.buf = c.buf[:0]
 	c.bufA = c.bufA[:0]
+	c.bufMK.buf, c.bufMK.val = c.bufMK.buf[:0], c.bufMK.val[:0]
+	c.bufRK.buf = c.bufRK.buf[:0]
 	if c.rl != nil {
 		c.rl.Reset()
 	}
@@ -233,21 +279,12 @@ func (c *Ctx) Reset() {
 // Internal getter.
 //
 // See Ctx.Get().
-func (c *Ctx) get(path []byte) interface{} {
+func (c *Ctx) get(path *vpath) interface{} {
 	// Reset error to avoid catching errors from previous nodes.
 	c.Err = nil
 
-	// Special case: check square brackets on counter loops.
-	// See Ctx.replaceQB().
-	if c.chQB {
-		path = c.replaceQB(path)
-	}
-
-	// Split path to separate words using dot as separator.
-	// So, path user.Bio.Birthday will convert to []string{"user", "Bio", "Birthday"}
-	c.bufS = c.bufS[:0]
-	c.bufS = bytealg.AppendSplitStr(c.bufS, fastconv.B2S(path), ".", -1)
-	if len(c.bufS) == 0 {
+	// Get list of keys of the path, so path user.Bio.Birthday will convert to []string{"user", "Bio", "Birthday"}
+	if !c.splitPath(path) || len(c.bufS) == 0 {
 		return nil
 	}
 
@@ -273,7 +310,7 @@ func (c *Ctx) get(path []byte) interface{} {
 			}
 			// Inspect variable using inspector object.
 			// Give search path as list of splitted path minus first key, e.g. []string{"Bio", "Birthday"}
-			c.Err = v.ins.GetTo(v.val, &c.bufX, c.bufS[1:]...)
+			c.Err = v.ins.GetTo(v.val, &c.bufX, c.varPath(&v)...)
 			if c.Err != nil {
 				return nil
 			}
@@ -285,11 +322,9 @@ func (c *Ctx) get(path []byte) interface{} {
 }
 
 // Compare method.
-func (c *Ctx) cmp(path []byte, cond Op, right []byte) bool {
+func (c *Ctx) cmp(path *vpath, cond Op, right []byte) bool {
 	// Split path.
-	c.bufS = c.bufS[:0]
-	c.bufS = bytealg.AppendSplitStr(c.bufS, fastconv.B2S(path), ".", -1)
-	if len(c.bufS) == 0 {
+	if !c.splitPath(path) || len(c.bufS) == 0 {
 		return false
 	}
 
@@ -300,9 +335,9 @@ func (c *Ctx) cmp(path []byte, cond Op, right []byte) bool {
 		if v.key == c.bufS[0] {
 			// Compare var with right value using inspector.
 			if v.cntrF {
-				c.Err = v.ins.Cmp(v.cntr, inspector.Op(cond), fastconv.B2S(right), &c.BufB, c.bufS[1:]...)
+				c.Err = v.ins.Cmp(v.cntr, inspector.Op(cond), fastconv.B2S(right), &c.BufB, c.varPath(&v)...)
 			} else {
-				c.Err = v.ins.Cmp(v.val, inspector.Op(cond), fastconv.B2S(right), &c.BufB, c.bufS[1:]...)
+				c.Err = v.ins.Cmp(v.val, inspector.Op(cond), fastconv.B2S(right), &c.BufB, c.varPath(&v)...)
 			}
 			if c.Err != nil {
 				return false
@@ -314,12 +349,30 @@ func (c *Ctx) cmp(path []byte, cond Op, right []byte) bool {
 	return false
 }
 
+// Set escape mode of raw text by mode node.
+func (c *Ctx) setMode(typ Type) {
+	switch typ {
+	case TypeJsonQ:
+		c.chJQ = true
+	case TypeEndJsonQ:
+		c.chJQ = false
+	case TypeHtmlE:
+		c.chHE = true
+	case TypeEndHtmlE:
+		c.chHE = false
+	case TypeUrlEnc:
+		c.chUE = true
+	case TypeEndUrlEnc:
+		c.chUE = false
+	}
+}
+
 // Range loop method to evaluate expressions like:
 // {% for k, v := range user.History %}...{% endfor %}
-func (c *Ctx) rloop(path []byte, node Node, tpl *Tpl, w io.Writer) {
-	c.bufS = c.bufS[:0]
-	c.bufS = bytealg.AppendSplitStr(c.bufS, fastconv.B2S(path), ".", -1)
-	if len(c.bufS) == 0 {
+//
+// See loopBody for the ways to render the body.
+func (c *Ctx) rloop(path *vpath, node *Node, tpl *Tpl, w io.Writer, body loopBody) {
+	if !c.splitPath(path) || len(c.bufS) == 0 {
 		return
 	}
 	for i, v := range c.vars {
@@ -331,7 +384,7 @@ func (c *Ctx) rloop(path []byte, node Node, tpl *Tpl, w io.Writer) {
 			var rl *RangeLoop
 			if c.rl == nil {
 				// No range loops, create new one.
-				c.rl = NewRangeLoop(node, tpl, c, w)
+				c.rl = &RangeLoop{}
 				rl = c.rl
 			} else {
 				// Move forward over the list while new RL will found.
@@ -349,22 +402,23 @@ func (c *Ctx) rloop(path []byte, node Node, tpl *Tpl, w io.Writer) {
 							continue
 						} else {
 							// End of the list, create new free RL and exit from the loop.
-							crl.next = NewRangeLoop(node, tpl, c, w)
+							crl.next = &RangeLoop{}
 							rl = crl.next
 							break
 						}
 					}
 				}
-				// Prepare RL object.
-				rl.cntr = 0
-				rl.node = node
-				rl.tpl = tpl
-				rl.ctx = c
-				rl.w = w
 			}
+			// Prepare RL object.
+			rl.cntr = 0
+			rl.node = node
+			rl.tpl = tpl
+			rl.ctx = c
+			rl.w = w
+			rl.body = body
 			// Mark RL as inuse and loop over var using inspector.
 			rl.stat = rlInuse
-			c.Err = v.ins.Loop(v.val, rl, &c.buf, c.bufS[1:]...)
+			c.Err = v.ins.Loop(v.val, rl, &c.buf, c.varPath(&v)...)
 			rl.stat = rlFree
 			return
 		}
@@ -373,18 +427,20 @@ func (c *Ctx) rloop(path []byte, node Node, tpl *Tpl, w io.Writer) {
 
 // Counter loop method to evaluate expressions like:
 // {% for i:=0; i<10; i++ %}...{% endfor %}
-func (c *Ctx) cloop(node Node, tpl *Tpl, w io.Writer) {
+//
+// See loopBody for the ways to render the body.
+func (c *Ctx) cloop(node *Node, tpl *Tpl, w io.Writer, body loopBody) {
 	var (
 		cnt, lim  int64
 		cntr      int
 		allowIter bool
 	)
 	// Prepare bounds.
-	cnt = c.cloopRange(node.loopCntStatic, node.loopCntInit)
+	cnt = c.cloopRange(node.loopCntStatic, node.loopCntInit, node.loopCntPath)
 	if c.Err != nil {
 		return
 	}
-	lim = c.cloopRange(node.loopLimStatic, node.loopLim)
+	lim = c.cloopRange(node.loopLimStatic, node.loopLim, node.loopLimPath)
 	if c.Err != nil {
 		return
 	}
@@ -423,16 +479,8 @@ func (c *Ctx) cloop(node Node, tpl *Tpl, w io.Writer) {
 			_, _ = w.Write(node.loopSep)
 		}
 		cntr++
-		// Loop over child nodes with square brackets check in paths.
-		c.chQB = true
-		var err error
-		for _, ch := range node.child {
-			err = tpl.renderNode(w, ch, c)
-			if err == ErrBreakLoop || err == ErrContLoop {
-				break
-			}
-		}
-		c.chQB = false
+		// Render the body.
+		err := body.render(w, tpl, node, c)
 
 		// Modify counter var.
 		switch node.loopCntOp {
@@ -459,7 +507,7 @@ func (c *Ctx) cloop(node Node, tpl *Tpl, w io.Writer) {
 // Counter loop bound check helper.
 //
 // Converts initial and final values of the counter to static int values.
-func (c *Ctx) cloopRange(static bool, b []byte) (r int64) {
+func (c *Ctx) cloopRange(static bool, b []byte, path *vpath) (r int64) {
 	if static {
 		r, c.Err = strconv.ParseInt(fastconv.B2S(b), 0, 0)
 		if c.Err != nil {
@@ -467,7 +515,7 @@ func (c *Ctx) cloopRange(static bool, b []byte) (r int64) {
 		}
 	} else {
 		var ok bool
-		raw := c.get(b)
+		raw := c.get(path)
 		if c.Err != nil {
 			return
 		}
@@ -480,43 +528,60 @@ func (c *Ctx) cloopRange(static bool, b []byte) (r int64) {
 	return
 }
 
-// Replaces square brackets with variable to concrete values, example:
+// Split the path to the list of keys in c.bufS.
+//
+// Index expressions evaluates and replaces with concrete values, example:
 // user.History[i] -> user.History.0, user.History.1, ...
 // , since inspector doesn't supports variadic paths.
-func (c *Ctx) replaceQB(path []byte) []byte {
-	qbLi := bytes.Index(path, qbL)
-	qbRi := bytes.Index(path, qbR)
-	if qbLi != -1 && qbRi != -1 && qbLi < qbRi && qbRi < len(path) {
-		c.Buf.Reset().Write(path[0:qbLi]).Write(dot)
-		c.chQB = false
-		c.bufX = c.get(path[qbLi+1 : qbRi])
-		if c.bufX != nil {
-			c.Buf1, c.Err = x2bytes.ToBytesWR(c.Buf1, c.bufX)
-			if c.Err != nil {
-				c.chQB = true
-				return nil
+func (c *Ctx) splitPath(path *vpath) bool {
+	c.bufS = c.bufS[:0]
+	if path == nil {
+		return true
+	}
+	if !path.dyn {
+		for i := 0; i < len(path.seg); i++ {
+			c.bufS = append(c.bufS, path.seg[i].key)
+		}
+		return true
+	}
+
+	// Evaluate index expressions first, since evaluation uses c.bufS.
+	if c.bufQD == 0 {
+		c.bufQ = c.bufQ[:0]
+	}
+	c.bufQD++
+	o := len(c.bufQO)
+	for i := 0; i < len(path.seg); i++ {
+		if path.seg[i].idx == nil {
+			continue
+		}
+		raw := c.get(path.seg[i].idx)
+		if c.Err != nil {
+			break
+		}
+		lo := len(c.bufQ)
+		if raw != nil {
+			if c.bufQ, c.Err = x2bytes.ToBytesWR(c.bufQ, raw); c.Err != nil {
+				break
 			}
-			c.Buf.Write(c.Buf1)
 		}
-		c.chQB = true
-		c.Buf.Write(path[qbRi+1:])
-		path = c.Buf
+		c.bufQO = append(c.bufQO, lo, len(c.bufQ))
+	}
+	c.bufQD--
+	if c.Err != nil {
+		c.bufQO = c.bufQO[:o]
+		return false
 	}
-	return path
-}
 
-// Get new or existing byte writer.
-//
-// Made to write output of including sub-templates.
-func (c *Ctx) getW() *bytes.Buffer {
-	if c.wl < len(c.w) {
-		b := &c.w[c.wl]
-		c.wl++
-		return b
-	} else {
-		b := bytes.Buffer{}
-		c.w = append(c.w, bytes.Buffer{})
-		c.wl++
-		return &b
+	c.bufS = c.bufS[:0]
+	for i, j := 0, o; i < len(path.seg); i++ {
+		if path.seg[i].idx == nil {
+			c.bufS = append(c.bufS, path.seg[i].key)
+			continue
+		}
+		c.bufS = append(c.bufS, fastconv.B2S(c.bufQ[c.bufQO[j]:c.bufQO[j+1]]))
+		j += 2
 	}
+	c.bufQO = c.bufQO[:o]
+	return true
 }
@@ -0,0 +1,135 @@
+package dyntpl
+
+import (
+	"github.com/koykov/fastconv"
+	"github.com/koykov/inspector"
+)
+
+// Scope of context variables.
+//
+// Scope allows to bind local variables (e.g. macro arguments) and hide outer variables with the same names. Closing of
+// the scope removes all variables that was set inside and restores the hidden ones.
+type ctxScope struct {
+	// Count of variables and hidden variables at the moment of scope opening.
+	ln, hl int
+}
+
+// Hidden variable, contains index in the variables list and original key.
+type ctxHidden struct {
+	idx int
+	key string
+}
+
+// Open new scope.
+func (c *Ctx) openScope() ctxScope {
+	return ctxScope{ln: c.ln, hl: len(c.hid)}
+}
+
+// Bind local variable to the argument.
+//
+// Static argument will be stored as bytes, variable argument will be bound as alias of the variable (or its field) from
+// the outer scope, e.g. binding of "user.Finance" as "fin" makes "fin.Balance" equal to "user.Finance.Balance".
+func (c *Ctx) bindVar(s ctxScope, key string, a *arg) {
+	ins, err := inspector.GetInspector("static")
+	if err != nil {
+		c.Err = err
+		return
+	}
+	v := c.newVar(key)
+	v.ins = ins
+	if a.static {
+		v.buf = append(v.buf[:0], a.val...)
+		return
+	}
+
+	// Keep own copy of the path since keys may point to temporary buffers.
+	if !c.splitPath(a.path) {
+		return
+	}
+	v.pfxB = v.pfxB[:0]
+	for _, k := range c.bufS {
+		v.pfxB = append(v.pfxB, k...)
+	}
+	v.pfx = v.pfx[:0]
+	for i, o := 0, 0; i < len(c.bufS); i++ {
+		v.pfx = append(v.pfx, fastconv.B2S(v.pfxB[o:o+len(c.bufS[i])]))
+		o += len(c.bufS[i])
+	}
+	if len(v.pfx) == 0 {
+		return
+	}
+	// Look for source variable in the outer scope.
+	for i := 0; i < s.ln; i++ {
+		src := &c.vars[i]
+		if src.key != v.pfx[0] {
+			continue
+		}
+		v.val, v.ins, v.cntrF, v.cntr = src.val, src.ins, src.cntrF, src.cntr
+		v.buf = append(v.buf[:0], src.buf...)
+		// Rest of the path becomes a prefix of alias.
+		c.bufS = append(c.bufS[:0], v.pfx[1:]...)
+		v.pfx = append(append(v.pfx[:0], src.pfx...), c.bufS...)
+		return
+	}
+	// Source not found, variable stays empty.
+	v.pfx = v.pfx[:0]
+}
+
+// Hide variable with given key from the outer scope.
+func (c *Ctx) hideVar(s ctxScope, key string) {
+	for i := 0; i < s.ln; i++ {
+		if c.vars[i].key == key {
+			c.hid = append(c.hid, ctxHidden{idx: i, key: key})
+			c.vars[i].key = ""
+		}
+	}
+}
+
+// Hide all variables from the outer scope.
+func (c *Ctx) hideAll(s ctxScope) {
+	for i := 0; i < s.ln; i++ {
+		if len(c.vars[i].key) > 0 {
+			c.hid = append(c.hid, ctxHidden{idx: i, key: c.vars[i].key})
+			c.vars[i].key = ""
+		}
+	}
+}
+
+// Close the scope.
+func (c *Ctx) closeScope(s ctxScope) {
+	for i := s.ln; i < c.ln; i++ {
+		c.vars[i].cntrF = false
+		c.vars[i].val = nil
+		c.vars[i].buf = c.vars[i].buf[:0]
+		c.vars[i].pfx = c.vars[i].pfx[:0]
+	}
+	c.ln = s.ln
+	for i := s.hl; i < len(c.hid); i++ {
+		c.vars[c.hid[i].idx].key = c.hid[i].key
+	}
+	c.hid = c.hid[:s.hl]
+}
+
+// Add new variable to the end of the list without checking of existing variables with the same key.
+func (c *Ctx) newVar(key string) *ctxVar {
+	if c.ln < len(c.vars) {
+		v := &c.vars[c.ln]
+		v.key = key
+		v.val, v.cntrF, v.cntr = nil, false, 0
+		v.buf, v.pfx = v.buf[:0], v.pfx[:0]
+		c.ln++
+		return v
+	}
+	c.vars = append(c.vars, ctxVar{key: key})
+	c.ln++
+	return &c.vars[c.ln-1]
+}
+
+// Get inspector's path of the variable, takes care of alias prefix.
+func (c *Ctx) varPath(v *ctxVar) []string {
+	if len(v.pfx) == 0 {
+		return c.bufS[1:]
+	}
+	c.bufP = append(append(c.bufP[:0], v.pfx...), c.bufS[1:]...)
+	return c.bufP
+}
@@ -69,6 +69,32 @@ func TestCtxGet(t *testing.T) {
 	}
 }
 
+func TestCtxGetIndex(t *testing.T) {
+	var (
+		ins testobj_ins.TestObjectInspector
+		raw interface{}
+	)
+	ctx := NewCtx()
+	ctx.Set("obj", testO, &ins)
+	ctx.SetBytes("i", []byte("1"))
+
+	raw = ctx.Get("obj.Finance.History[i].Cost")
+	if ctx.Err != nil {
+		t.Error("ctx get error", ctx.Err)
+	}
+	if *raw.(*float64) != -3.0000342543 {
+		t.Error("ctx get mismatch: obj.Finance.History[i].Cost")
+	}
+
+	raw = ctx.Get("obj.Finance.History[2].DateUnix")
+	if ctx.Err != nil {
+		t.Error("ctx get error", ctx.Err)
+	}
+	if *raw.(*int64) != 156436535640 {
+		t.Error("ctx get mismatch: obj.Finance.History[2].DateUnix")
+	}
+}
+
 func BenchmarkCtxGet(b *testing.B) {
 	var (
 		ins testobj_ins.TestObjectInspector
@@ -3,7 +3,7 @@ package dyntpl
 import (
 	"bytes"
 	"io"
-	"sync"
+	"time"
 
 	"github.com/koykov/bytealg"
 	"github.com/koykov/fastconv"
@@ -17,28 +17,112 @@ import (
 type Tpl struct {
 	Id   string
 	tree *Tree
+	// Set that owns the template, includes and parents of the template looks up there.
+	set *Set
+	// Time of registration, see TplInfo.
+	reg time.Time
+	// Source of template made for generated code, see MustGenTpl().
+	gen *genSrc
 }
 
 var (
-	// Templates registry.
-	mux         sync.Mutex
-	tplRegistry = map[string]*Tpl{}
-
 	// Suppress go vet warning.
 	_ = RenderFb
 )
 
-// Register template in the registry.
+// Register template in the default set.
 //
 // This function can be used in any time to register new templates or overwrite existing to provide dynamics.
 func RegisterTpl(id string, tree *Tree) {
+	defaultSet.RegisterTpl(id, tree)
+}
+
+// Register template in the set.
+//
+// See RegisterTpl().
+func (s *Set) RegisterTpl(id string, tree *Tree) {
 	tpl := Tpl{
 		Id:   id,
 		tree: tree,
+		set:  s,
+		reg:  time.Now(),
+	}
+	s.updTpl(func(g *tplGen) {
+		g.tpl.Store(id, &tpl)
+	})
+}
+
+// Remove template from the default set.
+//
+// Returns false if template isn't registered.
+func UnregisterTpl(id string) bool {
+	return defaultSet.UnregisterTpl(id)
+}
+
+// Remove template from the set.
+//
+// Removing changes current generation of the registry, so removed template still available in retained versions.
+func (s *Set) UnregisterTpl(id string) bool {
+	if s.getTpl(id) == nil {
+		return false
+	}
+	var ok bool
+	s.updTpl(func(g *tplGen) {
+		// Check again since template may be removed concurrently.
+		if _, ok = g.tpl.Load(id); ok {
+			g.tpl.Delete(id)
+		}
+	})
+	return ok
+}
+
+// Revalidate all templates of the default set in strict mode.
+//
+// Templates parsed by Parse() silently skip unknown modifiers, so call this function after registering new modifiers
+// and condition helpers. Each template is re-parsed by ParseStrict(), valid templates are re-registered with fresh trees
+// to apply new modifiers, invalid ones keep as is. Returns errors indexed by template ID or nil if all templates are
+// valid.
+func RevalidateTpl() map[string]error {
+	return defaultSet.RevalidateTpl()
+}
+
+// Revalidate all templates of the set in strict mode.
+//
+// See RevalidateTpl().
+func (s *Set) RevalidateTpl() map[string]error {
+	var (
+		errs map[string]error
+		// Fresh templates indexed by revalidated ones.
+		upd map[*Tpl]*Tpl
+	)
+	for _, tpl := range s.tpls() {
+		if tpl.tree == nil || tpl.tree.src == nil {
+			continue
+		}
+		tree, err := s.ParseStrict(tpl.tree.src, tpl.tree.keepFmt)
+		if err != nil {
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[tpl.Id] = err
+			continue
+		}
+		if upd == nil {
+			upd = make(map[*Tpl]*Tpl)
+		}
+		upd[tpl] = &Tpl{Id: tpl.Id, tree: tree, set: s, reg: time.Now()}
+	}
+	if len(upd) > 0 {
+		s.updTpl(func(g *tplGen) {
+			for old, tpl := range upd {
+				if g.get(old.Id) == old {
+					// Replace only if template wasn't overwritten during check.
+					g.tpl.Store(old.Id, tpl)
+				}
+			}
+		})
 	}
-	mux.Lock()
-	tplRegistry[id] = &tpl
-	mux.Unlock()
+	return errs
 }
 
 // Render template with id according given context.
@@ -46,9 +130,7 @@ func RegisterTpl(id string, tree *Tree) {
 // See RenderTo().
 // Recommend to use RenderTo() together with byte buffer pool to avoid redundant allocations.
 func Render(id string, ctx *Ctx) ([]byte, error) {
-	buf := bytes.Buffer{}
-	err := RenderTo(&buf, id, ctx)
-	return buf.Bytes(), err
+	return defaultSet.Render(id, ctx)
 }
 
 // Render template using fallback id.
@@ -65,101 +147,281 @@ func Render(id string, ctx *Ctx) ([]byte, error) {
 // call of dyntpl.RenderFbTo("tplUser-4", "tplUser", ctx) will take default template tplUser from registry.
 // Recommend to user RenderFbTo().
 func RenderFb(id, fbId string, ctx *Ctx) ([]byte, error) {
-	buf := bytes.Buffer{}
-	err := RenderFbTo(&buf, id, fbId, ctx)
-	return buf.Bytes(), err
+	return defaultSet.RenderFb(id, fbId, ctx)
 }
 
 // Render template to given writer object.
 //
 // Using this function together with byte buffer pool reduces allocations.
-func RenderTo(w io.Writer, id string, ctx *Ctx) (err error) {
-	mux.Lock()
-	tpl, ok := tplRegistry[id]
-	mux.Unlock()
-	if !ok {
-		err = ErrTplNotFound
-		return
-	}
-	return render(w, tpl, ctx)
+func RenderTo(w io.Writer, id string, ctx *Ctx) error {
+	return defaultSet.RenderTo(w, id, ctx)
 }
 
 // Render template using fallback ID logic and write result to writer object.
 //
 // See RenderFb().
 // Use this function together with byte buffer pool to reduce allocations.
-func RenderFbTo(w io.Writer, id, fbId string, ctx *Ctx) (err error) {
+func RenderFbTo(w io.Writer, id, fbId string, ctx *Ctx) error {
+	return defaultSet.RenderFbTo(w, id, fbId, ctx)
+}
+
+// Render template of the set with id according given context.
+//
+// See Render().
+func (s *Set) Render(id string, ctx *Ctx) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := s.RenderTo(&buf, id, ctx)
+	return buf.Bytes(), err
+}
+
+// Render template of the set using fallback id.
+//
+// See RenderFb().
+func (s *Set) RenderFb(id, fbId string, ctx *Ctx) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := s.RenderFbTo(&buf, id, fbId, ctx)
+	return buf.Bytes(), err
+}
+
+// Render template of the set to given writer object.
+//
+// See RenderTo().
+func (s *Set) RenderTo(w io.Writer, id string, ctx *Ctx) (err error) {
+	gen := s.cur()
+	tpl := gen.get(id)
+	if tpl == nil {
+		err = ErrTplNotFound
+		return
+	}
+	return renderGen(w, gen, tpl, ctx)
+}
+
+// Render template of the set using fallback ID logic and write result to writer object.
+//
+// See RenderFbTo().
+func (s *Set) RenderFbTo(w io.Writer, id, fbId string, ctx *Ctx) (err error) {
 	var (
-		tpl *Tpl
-		ok  bool
+		gen = s.cur()
+		tpl = gen.get(id)
 	)
-	mux.Lock()
-	tpl, ok = tplRegistry[id]
-	if !ok {
-		tpl, ok = tplRegistry[fbId]
+	if tpl == nil {
+		tpl = gen.get(fbId)
 	}
-	mux.Unlock()
-	if !ok {
+	if tpl == nil {
 		err = ErrTplNotFound
 		return
 	}
-	return render(w, tpl, ctx)
+	return renderGen(w, gen, tpl, ctx)
+}
+
+// Render template using given generation of the registry to look up included and parent templates.
+func renderGen(w io.Writer, gen *tplGen, tpl *Tpl, ctx *Ctx) error {
+	g := ctx.gen
+	ctx.gen = gen
+	err := render(w, tpl, ctx)
+	ctx.gen = g
+	return err
 }
 
 // Internal renderer.
 func render(w io.Writer, tpl *Tpl, ctx *Ctx) (err error) {
-	// Walk over root nodes in tree and evaluate them.
-	for _, node := range tpl.tree.nodes {
-		err = tpl.renderNode(w, node, ctx)
-		if err != nil {
-			if err == ErrInterrupt {
-				// Interrupt logic.
-				err = nil
-			}
+	if len(tpl.tree.ext) > 0 {
+		// Template extends another template, see renderExt().
+		err = renderExt(w, tpl, ctx)
+	} else {
+		// Walk over root nodes in tree and evaluate them.
+		// Template may be rendered inside the parent's block, so hide its inheritance chain.
+		extO := ctx.extO
+		ctx.extO = len(ctx.ext)
+		err = tpl.exec(w, ctx)
+		ctx.extO = extO
+	}
+	if err == ErrInterrupt {
+		// Interrupt logic.
+		err = nil
+	}
+	return
+}
+
+// Render list of nodes.
+func (t *Tpl) renderNodes(w io.Writer, nodes []Node, ctx *Ctx) (err error) {
+	for i := range nodes {
+		if err = t.renderNode(w, &nodes[i], ctx); err != nil {
 			return
 		}
 	}
-
 	return
 }
 
 // General node renderer.
-func (t *Tpl) renderNode(w io.Writer, node Node, ctx *Ctx) (err error) {
+func (t *Tpl) renderNode(w io.Writer, node *Node, ctx *Ctx) (err error) {
 	switch node.typ {
 	case TypeRaw:
-		if ctx.chJQ {
-			// JSON quote mode.
-			ctx.Buf.Reset().Write(node.raw)
-			ctx.Buf1 = jsonEscape(node.raw, ctx.Buf1)
-			_, err = w.Write(ctx.Buf1.Bytes())
-		} else if ctx.chHE {
-			// HTML escape mode.
-			ctx.Buf.Reset().Write(node.raw)
-			err = modHtmlEscape(ctx, &ctx.bufX, &ctx.Buf, nil)
-			if err != nil {
-				_, err = w.Write(node.raw)
-			} else {
-				_, err = w.Write(ctx.bufX.(*bytealg.ChainBuf).Bytes())
+		err = t.renderRaw(w, node, ctx)
+	case TypeTpl:
+		// Print variable.
+		err = t.renderPrint(w, node, ctx)
+	case TypeCtx:
+		err = t.renderCtx(node, ctx)
+	case TypeCounter:
+		err = t.renderCntr(node, ctx)
+	case TypeCond:
+		// Condition node evaluates condition expressions.
+		var r bool
+		if r, err = t.evalCond(node, ctx); err != nil {
+			return
+		}
+		// Evaluate condition.
+		if r {
+			// True case.
+			if len(node.child) > 0 {
+				err = t.renderNode(w, &node.child[0], ctx)
+			}
+			return
+		}
+		// Walk over else-if branches and the else case.
+		for i := 1; i < len(node.child); i++ {
+			ch := &node.child[i]
+			if ch.typ == TypeCondElif {
+				if r, err = t.evalCond(ch, ctx); err != nil {
+					return
+				}
+				if !r {
+					continue
+				}
+			}
+			err = t.renderNode(w, ch, ctx)
+			break
+		}
+	case TypeCondTrue, TypeCondFalse, TypeCondElif, TypeCase, TypeDefault:
+		// Just walk over child nodes.
+		err = t.renderNodes(w, node.child, ctx)
+	case TypeLoopCount:
+		// Evaluate counter loops.
+		// See Ctx.cloop().
+		ctx.cloop(node, t, w, loopBody{})
+		if ctx.Err != nil {
+			err = ctx.Err
+			return
+		}
+	case TypeLoopRange:
+		// Evaluate range loops.
+		// See Ctx.rloop().
+		ctx.rloop(node.loopSrcPath, node, t, w, loopBody{})
+		if ctx.Err != nil {
+			err = ctx.Err
+			return
+		}
+	case TypeBreak:
+		// Break the loop.
+		err = ErrBreakLoop
+	case TypeContinue:
+		// Go to next iteration of loop.
+		err = ErrContLoop
+	case TypeSwitch:
+		// Switch magic...
+		r := false
+		for i := 0; i < len(node.child); i++ {
+			ch := &node.child[i]
+			if ch.typ != TypeCase {
+				continue
 			}
-		} else if ctx.chUE {
-			// URL encode mode.
-			ctx.Buf.Reset().Write(node.raw)
-			err = modUrlEncode(ctx, &ctx.bufX, &ctx.Buf, nil)
-			if err != nil {
-				_, err = w.Write(node.raw)
-			} else {
-				_, err = w.Write(ctx.bufX.(*bytealg.ChainBuf).Bytes())
+			if r, err = t.evalCase(node, ch, ctx); err != nil {
+				return
 			}
+			if r {
+				err = t.renderNode(w, ch, ctx)
+				break
+			}
+		}
+		if !r {
+			for i := range node.child {
+				if ch := &node.child[i]; ch.typ == TypeDefault {
+					err = t.renderNode(w, ch, ctx)
+					break
+				}
+			}
+		}
+	case TypeInclude:
+		err = t.renderInclude(w, node, ctx)
+	case TypeExtends:
+		// Parent template is resolved on render start, see renderExt().
+	case TypeBlock:
+		// Render block or its override from the inheritance chain.
+		err = t.renderBlock(w, node.block, 0, node.child, ctx)
+	case TypeSuper:
+		// Render the parent's version of current block.
+		if len(ctx.blk.name) > 0 {
+			blk := ctx.blk
+			err = blk.tpl.renderBlock(w, blk.name, blk.lvl, blk.base, ctx)
+		}
+	case TypeMacro:
+		// Macro definition renders only on call.
+	case TypeCall:
+		// Render macro with given arguments.
+		err = t.renderMacro(w, node, ctx)
+	case TypeExit:
+		// Interrupt template evaluation.
+		err = ErrInterrupt
+	case TypeJsonQ, TypeEndJsonQ, TypeHtmlE, TypeEndHtmlE, TypeUrlEnc, TypeEndUrlEnc:
+		ctx.setMode(node.typ)
+	default:
+		// Unknown node type caught.
+		err = ErrUnknownCtl
+	}
+	return
+}
+
+// Raw node renderer.
+func (t *Tpl) renderRaw(w io.Writer, node *Node, ctx *Ctx) (err error) {
+	if ctx.chJQ {
+		// JSON quote mode.
+		ctx.Buf.Reset().Write(node.raw)
+		ctx.Buf1 = jsonEscape(node.raw, ctx.Buf1)
+		_, err = w.Write(ctx.Buf1.Bytes())
+	} else if ctx.chHE {
+		// HTML escape mode.
+		ctx.Buf.Reset().Write(node.raw)
+		err = modHtmlEscape(ctx, &ctx.bufX, &ctx.Buf, nil)
+		if err != nil {
+			_, err = w.Write(node.raw)
 		} else {
-			// Raw node writes as is.
+			_, err = w.Write(ctx.bufX.(*bytealg.ChainBuf).Bytes())
+		}
+	} else if ctx.chUE {
+		// URL encode mode.
+		ctx.Buf.Reset().Write(node.raw)
+		err = modUrlEncode(ctx, &ctx.bufX, &ctx.Buf, nil)
+		if err != nil {
 			_, err = w.Write(node.raw)
+		} else {
+			_, err = w.Write(ctx.bufX.(*bytealg.ChainBuf).Bytes())
 		}
-	case TypeTpl:
-		// Get data from the context.
-		raw := ctx.get(node.raw)
+	} else {
+		// Raw node writes as is.
+		_, err = w.Write(node.raw)
+	}
+	return
+}
+
+// Context node renderer.
+func (t *Tpl) renderCtx(node *Node, ctx *Ctx) (err error) {
+	// Context node sets new variable, example:
+	// {% ctx name = user.Name %} or {% ctx limit = 10 %}
+	// It's a speed improvement trick.
+	if node.ctxSrcStatic {
+		ctx.SetBytes(fastconv.B2S(node.ctxVar), node.ctxSrc)
+	} else {
+		// Get the inspector.
+		ins, err := inspector.GetInspector(fastconv.B2S(node.ctxIns))
+		if err != nil {
+			return err
+		}
+
+		raw := ctx.get(node.ctxSrcPath)
 		if ctx.Err != nil {
 			err = ctx.Err
-			return
+			return err
 		}
 		// Process modifiers.
 		if len(node.mod) > 0 {
@@ -171,7 +433,7 @@ func (t *Tpl) renderNode(w io.Writer, node Node, ctx *Ctx) (err error) {
 						if arg.static {
 							ctx.bufA = append(ctx.bufA, &arg.val)
 						} else {
-							val := ctx.get(arg.val)
+							val := ctx.get(arg.path)
 							ctx.bufA = append(ctx.bufA, val)
 						}
 					}
@@ -186,336 +448,259 @@ func (t *Tpl) renderNode(w io.Writer, node Node, ctx *Ctx) (err error) {
 			}
 		}
 		if ctx.Err != nil {
-			return
+			err = ctx.Err
+			return err
 		}
 		if raw == nil || raw == "" {
 			err = ErrEmptyArg
+			return err
+		}
+
+		if b, ok := ConvBytes(raw); ok && len(b) > 0 {
+			// Set byte array as bytes variable if possible.
+			ctx.SetBytes(fastconv.B2S(node.ctxVar), b)
+		} else {
+			ctx.Set(fastconv.B2S(node.ctxVar), raw, ins)
+		}
+	}
+	return
+}
+
+// Counter node renderer.
+func (t *Tpl) renderCntr(node *Node, ctx *Ctx) (err error) {
+	if node.cntrInitF {
+		ctx.setCntr(fastconv.B2S(node.cntrVar), node.cntrInit)
+	} else {
+		raw := ctx.get(node.cntrVarPath)
+		if ctx.Err != nil {
+			err = ctx.Err
 			return
 		}
-		// Convert modified data to bytes array.
-		ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, raw)
-		if err == nil {
-			if len(node.prefix) > 0 {
-				// Write prefix.
-				_, _ = w.Write(node.prefix)
-			}
-			// Write bytes data.
-			_, err = w.Write(ctx.Buf)
-			// Write suffix.
-			if len(node.suffix) > 0 {
-				_, _ = w.Write(node.suffix)
-			}
+		var cntr int
+		if cntr64, ok := ConvInt(raw); ok {
+			cntr = int(cntr64)
 		}
-	case TypeCtx:
-		// Context node sets new variable, example:
-		// {% ctx name = user.Name %} or {% ctx limit = 10 %}
-		// It's a speed improvement trick.
-		if node.ctxSrcStatic {
-			ctx.SetBytes(fastconv.B2S(node.ctxVar), node.ctxSrc)
+		if node.cntrOp == OpInc {
+			cntr += node.cntrOpArg
 		} else {
-			// Get the inspector.
-			ins, err := inspector.GetInspector(fastconv.B2S(node.ctxIns))
-			if err != nil {
-				return err
-			}
-
-			raw := ctx.get(node.ctxSrc)
-			if ctx.Err != nil {
-				err = ctx.Err
-				return err
-			}
-			// Process modifiers.
-			if len(node.mod) > 0 {
-				for _, mod := range node.mod {
-					// Collect arguments to buffer.
-					ctx.bufA = ctx.bufA[:0]
-					if len(mod.arg) > 0 {
-						for _, arg := range mod.arg {
-							if arg.static {
-								ctx.bufA = append(ctx.bufA, &arg.val)
-							} else {
-								val := ctx.get(arg.val)
-								ctx.bufA = append(ctx.bufA, val)
-							}
-						}
-					}
-					ctx.bufX = raw
-					// Call the modifier func.
-					ctx.Err = (*mod.fn)(ctx, &ctx.bufX, ctx.bufX, ctx.bufA)
-					if ctx.Err != nil {
-						break
-					}
-					raw = ctx.bufX
-				}
-			}
-			if ctx.Err != nil {
-				err = ctx.Err
-				return err
-			}
-			if raw == nil || raw == "" {
-				err = ErrEmptyArg
-				return err
-			}
-
-			if b, ok := ConvBytes(raw); ok && len(b) > 0 {
-				// Set byte array as bytes variable if possible.
-				ctx.SetBytes(fastconv.B2S(node.ctxVar), b)
-			} else {
-				ctx.Set(fastconv.B2S(node.ctxVar), raw, ins)
-			}
+			cntr -= node.cntrOpArg
 		}
-	case TypeCounter:
-		if node.cntrInitF {
-			ctx.setCntr(fastconv.B2S(node.cntrVar), node.cntrInit)
+		ctx.setCntr(fastconv.B2S(node.cntrVar), cntr)
+	}
+	return
+}
+
+// Include node renderer.
+func (t *Tpl) renderInclude(w io.Writer, node *Node, ctx *Ctx) (err error) {
+	// Include sub-template expression.
+	var tpl *Tpl
+	for i := 0; i < len(node.tpl) && tpl == nil; i++ {
+		tpl = t.lookup(fastconv.B2S(node.tpl[i]), ctx)
+	}
+	if tpl != nil {
+		// Sub-template writes directly to the parent's writer. Its exit interrupts only the sub-template itself,
+		// since render() consumes ErrInterrupt.
+		if len(node.incArg) > 0 || node.incOnly {
+			err = renderIncScope(w, tpl, node, ctx)
 		} else {
-			raw := ctx.get(node.cntrVar)
-			if ctx.Err != nil {
-				err = ctx.Err
-				return
-			}
-			var cntr int
-			if cntr64, ok := ConvInt(raw); ok {
-				cntr = int(cntr64)
-			}
-			if node.cntrOp == OpInc {
-				cntr += node.cntrOpArg
-			} else {
-				cntr -= node.cntrOpArg
-			}
-			ctx.setCntr(fastconv.B2S(node.cntrVar), cntr)
+			err = render(w, tpl, ctx)
 		}
-	case TypeCond:
-		// Condition node evaluates condition expressions.
-		var r bool
-		if len(node.condHlp) > 0 {
-			// Condition helper caught.
-			fn := GetCondFn(fastconv.B2S(node.condHlp))
-			if fn == nil {
-				err = ErrCondHlpNotFound
-				return
-			}
-			// Prepare arguments list.
+	} else {
+		err = ErrTplNotFound
+	}
+	return
+}
+
+// Print variable node.
+func (t *Tpl) renderPrint(w io.Writer, node *Node, ctx *Ctx) (err error) {
+	// Get data from the context.
+	raw := ctx.get(node.rawPath)
+	if ctx.Err != nil {
+		err = ctx.Err
+		return
+	}
+	// Process modifiers.
+	if len(node.mod) > 0 {
+		for _, mod := range node.mod {
+			// Collect arguments to buffer.
 			ctx.bufA = ctx.bufA[:0]
-			if len(node.condHlpArg) > 0 {
-				for _, arg := range node.condHlpArg {
+			if len(mod.arg) > 0 {
+				for _, arg := range mod.arg {
 					if arg.static {
 						ctx.bufA = append(ctx.bufA, &arg.val)
 					} else {
-						val := ctx.get(arg.val)
+						val := ctx.get(arg.path)
 						ctx.bufA = append(ctx.bufA, val)
 					}
 				}
 			}
-			// Call condition helper func.
-			r = (*fn)(ctx, ctx.bufA)
-		} else {
-			// Regular comparison.
-			sl := node.condStaticL
-			sr := node.condStaticR
-			if sl && sr {
-				// It's senseless to compare two static values.
-				err = ErrSenselessCond
-				return
-			}
-			if sr {
-				// Right side is static. This is a prefer case
-				r = ctx.cmp(node.condL, node.condOp, node.condR)
-			} else if sl {
-				// Left side is static.
-				// dyntpl can't handle expressions like {% if 10 > item.Weight %}...
-				// therefore it inverts condition to {% if item.Weight < 10 %}...
-				r = ctx.cmp(node.condR, node.condOp.Swap(), node.condL)
-			} else {
-				// Both sides isn't static. This is a bad case, since need to inspect variables twice.
-				ctx.get(node.condR)
-				if ctx.Err == nil {
-					ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, ctx.bufX)
-					if err != nil {
-						return
-					}
-					r = ctx.cmp(node.condL, node.condOp, ctx.Buf)
-				}
+			ctx.bufX = raw
+			// Call the modifier func.
+			ctx.Err = (*mod.fn)(ctx, &ctx.bufX, ctx.bufX, ctx.bufA)
+			if ctx.Err != nil {
+				break
 			}
+			raw = ctx.bufX
 		}
-		if ctx.Err != nil {
-			err = ctx.Err
-			return
+	}
+	if ctx.Err != nil {
+		return
+	}
+	if raw == nil || raw == "" {
+		err = ErrEmptyArg
+		return
+	}
+	// Convert modified data to bytes array.
+	ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, raw)
+	if err == nil {
+		if len(node.prefix) > 0 {
+			// Write prefix.
+			_, _ = w.Write(node.prefix)
 		}
-		// Evaluate condition.
-		if r {
-			// True case.
-			if len(node.child) > 0 {
-				err = t.renderNode(w, node.child[0], ctx)
-			}
-		} else {
-			// Else case.
-			if len(node.child) > 1 {
-				err = t.renderNode(w, node.child[1], ctx)
-			}
+		// Write bytes data.
+		_, err = w.Write(ctx.Buf)
+		// Write suffix.
+		if len(node.suffix) > 0 {
+			_, _ = w.Write(node.suffix)
 		}
-	case TypeCondTrue, TypeCondFalse, TypeCase, TypeDefault:
-		// Just walk over child nodes.
-		for _, ch := range node.child {
-			err = t.renderNode(w, ch, ctx)
-			if err != nil {
-				return
+	}
+	return
+}
+
+// Evaluate case of the switch node.
+func (t *Tpl) evalCase(node, ch *Node, ctx *Ctx) (r bool, err error) {
+	if ch.caseExpr != nil {
+		// Complex case condition caught, evaluate the expression tree.
+		return ch.caseExpr.eval(ctx)
+	}
+	if len(node.switchArg) > 0 {
+		// Classic switch case.
+		if ch.caseStaticL {
+			r = ctx.cmp(node.switchArgPath, OpEq, ch.caseL)
+		} else {
+			ctx.get(ch.caseLPath)
+			if ctx.Err == nil {
+				ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, ctx.bufX)
+				if err != nil {
+					return
+				}
+				r = ctx.cmp(node.switchArgPath, OpEq, ctx.Buf)
 			}
 		}
-	case TypeLoopCount:
-		// Evaluate counter loops.
-		// See Ctx.cloop().
-		ctx.cloop(node, t, w)
-		if ctx.Err != nil {
-			err = ctx.Err
-			return
-		}
-	case TypeLoopRange:
-		// Evaluate range loops.
-		// See Ctx.rloop().
-		ctx.rloop(node.loopSrc, node, t, w)
-		if ctx.Err != nil {
-			err = ctx.Err
+		return
+	}
+	// Switch without condition case.
+	if len(ch.caseHlp) > 0 {
+		// Case condition helper caught.
+		fn := ch.caseHlpFn
+		if fn == nil {
+			err = ErrCondHlpNotFound
 			return
 		}
-	case TypeBreak:
-		// Break the loop.
-		err = ErrBreakLoop
-	case TypeContinue:
-		// Go to next iteration of loop.
-		err = ErrContLoop
-	case TypeSwitch:
-		// Switch magic...
-		r := false
-		if len(node.switchArg) > 0 {
-			// Classic switch case.
-			for _, ch := range node.child {
-				if ch.typ == TypeCase {
-					if ch.caseStaticL {
-						r = ctx.cmp(node.switchArg, OpEq, ch.caseL)
-					} else {
-						ctx.get(ch.caseL)
-						if ctx.Err == nil {
-							ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, ctx.bufX)
-							if err != nil {
-								return
-							}
-							r = ctx.cmp(node.switchArg, OpEq, ctx.Buf)
-						}
-					}
-				}
-				if r {
-					err = t.renderNode(w, ch, ctx)
-					break
+		// Prepare arguments list.
+		ctx.bufA = ctx.bufA[:0]
+		if len(ch.caseHlpArg) > 0 {
+			for _, arg := range ch.caseHlpArg {
+				if arg.static {
+					ctx.bufA = append(ctx.bufA, &arg.val)
+				} else {
+					val := ctx.get(arg.path)
+					ctx.bufA = append(ctx.bufA, val)
 				}
 			}
+		}
+		// Call condition helper func.
+		r = (*fn)(ctx, ctx.bufA)
+	} else {
+		sl := ch.caseStaticL
+		sr := ch.caseStaticR
+		if sl && sr {
+			err = ErrSenselessCond
+			return
+		}
+		if sr {
+			// Right side is static.
+			r = ctx.cmp(ch.caseLPath, ch.caseOp, ch.caseR)
+		} else if sl {
+			// Left side is static.
+			r = ctx.cmp(ch.caseRPath, ch.caseOp.Swap(), ch.caseL)
 		} else {
-			// Switch without condition case.
-			for _, ch := range node.child {
-				if ch.typ == TypeCase {
-					if len(ch.caseHlp) > 0 {
-						// Case condition helper caught.
-						fn := GetCondFn(fastconv.B2S(ch.caseHlp))
-						if fn == nil {
-							err = ErrCondHlpNotFound
-							return
-						}
-						// Prepare arguments list.
-						ctx.bufA = ctx.bufA[:0]
-						if len(ch.caseHlpArg) > 0 {
-							for _, arg := range ch.caseHlpArg {
-								if arg.static {
-									ctx.bufA = append(ctx.bufA, &arg.val)
-								} else {
-									val := ctx.get(arg.val)
-									ctx.bufA = append(ctx.bufA, val)
-								}
-							}
-						}
-						// Call condition helper func.
-						r = (*fn)(ctx, ctx.bufA)
-					} else {
-						sl := ch.caseStaticL
-						sr := ch.caseStaticR
-						if sl && sr {
-							err = ErrSenselessCond
-							return
-						}
-						if sr {
-							// Right side is static.
-							r = ctx.cmp(ch.caseL, ch.caseOp, ch.caseR)
-						} else if sl {
-							// Left side is static.
-							r = ctx.cmp(ch.caseR, ch.caseOp.Swap(), ch.caseL)
-						} else {
-							// Both sides isn't static.
-							ctx.get(ch.caseR)
-							if ctx.Err == nil {
-								ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, ctx.bufX)
-								if err != nil {
-									return
-								}
-								r = ctx.cmp(ch.caseL, ch.caseOp, ctx.Buf)
-							}
-						}
-					}
-					if ctx.Err != nil {
-						err = ctx.Err
-						return
-					}
-					if r {
-						err = t.renderNode(w, ch, ctx)
-						break
-					}
+			// Both sides isn't static.
+			ctx.get(ch.caseRPath)
+			if ctx.Err == nil {
+				ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, ctx.bufX)
+				if err != nil {
+					return
 				}
+				r = ctx.cmp(ch.caseLPath, ch.caseOp, ctx.Buf)
 			}
 		}
-		if !r {
-			for _, ch := range node.child {
-				if ch.typ == TypeDefault {
-					err = t.renderNode(w, ch, ctx)
-					break
+	}
+	if ctx.Err != nil {
+		err = ctx.Err
+	}
+	return
+}
+
+// Evaluate condition of the condition node or else-if branch.
+func (t *Tpl) evalCond(node *Node, ctx *Ctx) (r bool, err error) {
+	if node.condExpr != nil {
+		// Complex condition caught, evaluate the expression tree.
+		if r, err = node.condExpr.eval(ctx); err != nil {
+			return
+		}
+	} else if len(node.condHlp) > 0 {
+		// Condition helper caught.
+		fn := node.condHlpFn
+		if fn == nil {
+			err = ErrCondHlpNotFound
+			return
+		}
+		// Prepare arguments list.
+		ctx.bufA = ctx.bufA[:0]
+		if len(node.condHlpArg) > 0 {
+			for _, arg := range node.condHlpArg {
+				if arg.static {
+					ctx.bufA = append(ctx.bufA, &arg.val)
+				} else {
+					val := ctx.get(arg.path)
+					ctx.bufA = append(ctx.bufA, val)
 				}
 			}
 		}
-	case TypeInclude:
-		// Include sub-template expression.
-		var tpl *Tpl
-		mux.Lock()
-		for i := 0; i < len(node.tpl); i++ {
-			if t, ok := tplRegistry[fastconv.B2S(node.tpl[i])]; ok {
-				tpl = t
-				break
-			}
+		// Call condition helper func.
+		r = (*fn)(ctx, ctx.bufA)
+	} else {
+		// Regular comparison.
+		sl := node.condStaticL
+		sr := node.condStaticR
+		if sl && sr {
+			// It's senseless to compare two static values.
+			err = ErrSenselessCond
+			return
 		}
-		mux.Unlock()
-		if tpl != nil {
-			w1 := ctx.getW()
-			if err = render(w1, tpl, ctx); err != nil {
-				return
-			}
-
-			_, err = w.Write(w1.Bytes())
+		if sr {
+			// Right side is static. This is a prefer case
+			r = ctx.cmp(node.condLPath, node.condOp, node.condR)
+		} else if sl {
+			// Left side is static.
+			// dyntpl can't handle expressions like {% if 10 > item.Weight %}...
+			// therefore it inverts condition to {% if item.Weight < 10 %}...
+			r = ctx.cmp(node.condRPath, node.condOp.Swap(), node.condL)
 		} else {
-			err = ErrTplNotFound
+			// Both sides isn't static. This is a bad case, since need to inspect variables twice.
+			ctx.get(node.condRPath)
+			if ctx.Err == nil {
+				ctx.Buf, err = x2bytes.ToBytesWR(ctx.Buf, ctx.bufX)
+				if err != nil {
+					return
+				}
+				r = ctx.cmp(node.condLPath, node.condOp, ctx.Buf)
+			}
 		}
-	case TypeExit:
-		// Interrupt template evaluation.
-		err = ErrInterrupt
-	case TypeJsonQ:
-		ctx.chJQ = true
-	case TypeEndJsonQ:
-		ctx.chJQ = false
-	case TypeHtmlE:
-		ctx.chHE = true
-	case TypeEndHtmlE:
-		ctx.chHE = false
-	case TypeUrlEnc:
-		ctx.chUE = true
-	case TypeEndUrlEnc:
-		ctx.chUE = false
-	default:
-		// Unknown node type caught.
-		err = ErrUnknownCtl
+	}
+	if ctx.Err != nil {
+		err = ctx.Err
 	}
 	return
 }
@@ -2,6 +2,7 @@ package dyntpl
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/koykov/inspector/testobj"
@@ -63,6 +64,26 @@ var (
 	tplCondHlp    = []byte(`{% if lenGt0(user.Id) %}greater than zero{% endif %}`)
 	expectCondHlp = []byte(`greater than zero`)
 
+	tplCondComplex = []byte(`{% if user.Status >= 60 && (user.Finance.AllowBuy || !lenEq0(user.Id)) %}
+	allowed
+{% else %}
+	denied
+{% endif %}
+{% if !(user.Status > 60) || user.Finance.AllowBuy %} unreachable{% endif %}`)
+	expectCondComplex = []byte(`allowed`)
+	tplCondElif       = []byte(`{% if user.Status < 10 %}
+	anonymous
+{% elseif user.Status < 45 %}
+	logged in
+{% elif lenEq0(user.Id) %}
+	no id
+{% else if user.Status >= 60 && !lenEq0(user.Name) %}
+	privileged
+{% else %}
+	unknown
+{% endif %}`)
+	expectCondElif = []byte(`privileged`)
+
 	tplSwitch = []byte(`{% ctx exactStatus = 78 %}{
 	"permission": "{% switch user.Status %}
 	{% case 10 %}
@@ -86,6 +107,16 @@ var (
 	{% default %}
 		unknown
 {% endswitch %}"
+}`)
+	tplSwitchComplex = []byte(`{
+	"permission": "{% switch %}
+	{% case user.Status < 10 || user.Status > 100 %}
+		unknown
+	{% case user.Status >= 60 && !lenEq0(user.Name) %}
+		privileged
+	{% default %}
+		logged in
+{% endswitch %}"
 }`)
 	expectSwitch = []byte(`{"permission": "privileged"}`)
 
@@ -104,6 +135,8 @@ var (
 }`)
 	expectLoopRange = []byte(`{"id":"115","name":"John","fin_history":[0:{"utime":152354345634,"cost":14.345241,"desc":"pay for domain"},1:{"utime":153465345246,"cost":-3.0000342543,"desc":"got refund"},2:{"utime":156436535640,"cost":2325242534.3532453,"desc":"maintenance"}]}`)
 
+	tplLoopHeavy = []byte(`{% for i := 0; i < 10; i++ %}{% for _, item := range user.Finance.History sep , %}{% switch item.DateUnix %}{% case 153465345246 %}+{% default %}-{% endswitch %}{% if item.DateUnix > 153000000000 %}{%= item.Comment %}{% else %}old{% endif %}{% endfor %};{% endfor %}`)
+
 	tplLoopCountStatic = []byte(`<h2>History</h2>
 <ul>
 	{% for i := 0; i < 3; i++ %}
@@ -173,12 +206,74 @@ var (
 
 	tplExit = []byte(`{% if user.Status < 100 %}{% exit %}{% endif %}foobar`)
 
+	tplExtLayout = []byte(`<html>
+	<title>{% block title %}Default{% endblock %}</title>
+	<body>{% block body %}empty{% endblock %}</body>
+</html>`)
+	tplExtChild = []byte(`{% extends "tplExtLayout" %}
+ignored content
+{% block title %}User {%= user.Name %}{% endblock %}
+{% block body %}<p>{% block content %}{% endblock %}</p>{% endblock %}`)
+	tplExtGrandchild = []byte(`{% extends tplExtChild %}
+{% block title %}{% super %} - profile{% endblock %}
+{% block content %}Status: {%= user.Status %}{% endblock %}`)
+	expectExtChild      = []byte(`<html><title>User John</title><body><p></p></body></html>`)
+	expectExtGrandchild = []byte(`<html><title>User John - profile</title><body><p>Status: 78</p></body></html>`)
+
+	tplMacro = []byte(`{% ctx title = "outer" %}
+{% macro row(item, idx, title="item") %}
+	{"idx":{%= idx %},"title":"{%= title %}","cost":{%= item.Cost %}}
+{% endmacro %}
+[{% for k, h := range user.Finance.History sep , %}{%= call row(h, k) %}{% endfor %}]{%= title %}`)
+	expectMacro = []byte(`[{"idx":0,"title":"item","cost":14.345241},{"idx":1,"title":"item","cost":-3.0000342543},{"idx":2,"title":"item","cost":2325242534.3532453}]outer`)
+
+	tplMacroArgs    = []byte(`{% macro m(title="a, b", sep=", ") %}{%= title %}{%= sep %}{% endmacro %}{%= call m() %}|{%= call m("f(x)") %}|{%= call m("c, d", "g(y, z)") %}`)
+	expectMacroArgs = []byte(`a, b, |f(x), |c, dg(y, z)`)
+
+	tplIndex    = []byte(`{%= user.Flags["export"] %}|{% for k, h := range user.Finance.History %}{% if user.Finance.History[k].Cost > 0 %}{%= user.Finance.History[k].Comment %};{% endif %}{% endfor %}|{%= user.Finance.History[0].DateUnix %}|{%= user.Finance.History[user.Flags["Valid"]].Comment %}|{% for i := 2; i > 0; i-- %}{%= user.Finance.History[i].Comment %},{% endfor %}`)
+	expectIndex = []byte(`17|pay for domain;maintenance;|152354345634|got refund|maintenance,got refund,`)
+
 	tplIncHost     = []byte(`foo {% include sub %} bar`)
 	tplIncSub      = []byte(`welcome {%= user.Name %}!`)
 	expectTplInc   = []byte(`foo welcome John! bar`)
 	tplIncHostJS   = []byte(`{"a":"{% include sub1 subjs %}"}`)
 	tplIncSubJS    = []byte(`welcome {%j= user.Id|default('anon') %}!`)
 	expectTplIncJS = []byte(`{"a":"welcome 115!"}`)
+
+	tplIncArgHost    = []byte(`{% ctx title = "outer" %}[{% for k, h := range user.Finance.History sep , %}{% include subarg with item=h, idx=k %}{% endfor %}]{%= title %}`)
+	tplIncArgSub     = []byte(`{"idx":{%= idx %},"cost":{%= item.Cost %},"user":"{%= user.Name %}"}`)
+	expectTplIncArg  = []byte(`[{"idx":0,"cost":14.345241,"user":"John"},{"idx":1,"cost":-3.0000342543,"user":"John"},{"idx":2,"cost":2325242534.3532453,"user":"John"}]outer`)
+	tplIncOnlyHost   = []byte(`{% include subonly with name=user.Name only %}`)
+	tplIncOnlySub    = []byte(`{%= name %}:{% if user.Name == "John" %}visible{% else %}hidden{% endif %}`)
+	expectTplIncOnly = []byte(`John:hidden`)
+
+	tplIncScopeHost   = []byte(`{% counter cnt = 0 %}{% include subscope with cnt only %}{%= cnt %}|{% include subscope %}{%= cnt %},{%= title %}`)
+	tplIncScopeSub    = []byte(`{% ctx title = "inner" %}{% counter cnt++ %}`)
+	expectTplIncScope = []byte(`0|1,inner`)
+
+	tplIncExitHost    = []byte(`foo {% include subexit %} bar`)
+	tplIncExitSub     = []byte(`welcome {%= user.Name %}{% exit %} ignored`)
+	expectTplIncExit  = []byte(`foo welcome John bar`)
+	tplIncPartHost    = []byte(`foo {% include subpart %} bar`)
+	tplIncPartSub     = []byte(`partial {% include nosuch %} rest`)
+	expectTplIncPart  = []byte(`foo partial `)
+	tplIncLargeHost   = []byte(`foo {% include sublarge %} bar`)
+	tplIncLargeSub    = bytes.Repeat([]byte(`<li>lorem ipsum</li>`), 1024)
+	expectTplIncLarge = append(append([]byte(`foo `), tplIncLargeSub...), ` bar`...)
+
+	tplJSON    = []byte(`{%= doc.title %}|{% for k, v := range doc.tags %}{%= k %}={%= v %};{% endfor %}|{% for k, v := range doc.attrs %}{%= k %}:{%= v %},{% endfor %}|{% if doc.count > 2 %}many{% endif %}|{% if doc.active == true %}on{% endif %}|{%= doc.items[1].name %}|{% ctx a = doc.attrs as map %}{%= a.x %}|{%= env.mode %}`)
+	docJSON    = []byte(`{"title":"Doc","tags":["a","b"],"attrs":{"y":2,"x":"1"},"count":3,"active":true,"items":[{"name":"i0"},{"name":"i1"}]}`)
+	expectJSON = []byte(`Doc|0=a;1=b;|x:1,y:2,|many|on|i1|1|dev`)
+	tplMapLoop = []byte(`{% for k, v := range doc %}{%= k %}[{% for k1, v1 := range v %}{%= k1 %}={%= v1 %};{% endfor %}]{% endfor %}`)
+	docMap     = map[string]interface{}{
+		"b": map[string]string{"z": "1", "y": "2"},
+		"a": map[string]interface{}{"x": "3", "w": "4"},
+	}
+	expectMapLoop = []byte(`a[w=4;x=3;]b[y=2;z=1;]`)
+	docMapTyped   = map[string]map[string]int{
+		"b": {"z": 1, "y": 2},
+		"a": {"x": 3, "w": 4},
+	}
 )
 
 func pretest() {
@@ -188,8 +283,11 @@ func pretest() {
 		"tplCond":              tplCond,
 		"tplCondNoStatic":      tplCondNoStatic,
 		"tplCondHlp":           tplCondHlp,
+		"tplCondComplex":       tplCondComplex,
+		"tplCondElif":          tplCondElif,
 		"tplSwitch":            tplSwitch,
 		"tplSwitchNoCond":      tplSwitchNoCond,
+		"tplSwitchComplex":     tplSwitchComplex,
 		"tplLoopRange":         tplLoopRange,
 		"tplLoopCountStatic":   tplLoopCountStatic,
 		"tplLoopCountBreak":    tplLoopCountBreak,
@@ -215,10 +313,36 @@ func pretest() {
 		"tplModIfThenElse":      tplModIfThenElse,
 		"tplModRound":           tplModRound,
 
+		"tplExtLayout":     tplExtLayout,
+		"tplExtChild":      tplExtChild,
+		"tplExtGrandchild": tplExtGrandchild,
+
+		"tplMacro":     tplMacro,
+		"tplMacroArgs": tplMacroArgs,
+
+		"tplIndex": tplIndex,
+
 		"tplIncHost":   tplIncHost,
 		"sub":          tplIncSub,
 		"tplIncHostJS": tplIncHostJS,
 		"subjs":        tplIncSubJS,
+
+		"tplIncArgHost":   tplIncArgHost,
+		"subarg":          tplIncArgSub,
+		"tplIncOnlyHost":  tplIncOnlyHost,
+		"subonly":         tplIncOnlySub,
+		"tplIncScopeHost": tplIncScopeHost,
+		"subscope":        tplIncScopeSub,
+
+		"tplIncExitHost":  tplIncExitHost,
+		"subexit":         tplIncExitSub,
+		"tplIncPartHost":  tplIncPartHost,
+		"subpart":         tplIncPartSub,
+		"tplIncLargeHost": tplIncLargeHost,
+		"sublarge":        tplIncLargeSub,
+
+		"tplJSON":    tplJSON,
+		"tplMapLoop": tplMapLoop,
 	}
 	for name, body := range tpl {
 		tree, _ := Parse(body, false)
@@ -285,6 +409,14 @@ func TestTplCondHlp(t *testing.T) {
 	testBase(t, "tplCondHlp", expectCondHlp, "cond (helper) tpl mismatch")
 }
 
+func TestTplCondComplex(t *testing.T) {
+	testBase(t, "tplCondComplex", expectCondComplex, "cond (complex) tpl mismatch")
+}
+
+func TestTplCondElif(t *testing.T) {
+	testBase(t, "tplCondElif", expectCondElif, "cond (else-if) tpl mismatch")
+}
+
 func TestTplSwitch(t *testing.T) {
 	testBase(t, "tplSwitch", expectSwitch, "switch tpl mismatch")
 }
@@ -293,6 +425,10 @@ func TestTplSwitchNoCond(t *testing.T) {
 	testBase(t, "tplSwitchNoCond", expectSwitch, "switch (no cond) tpl mismatch")
 }
 
+func TestTplSwitchComplex(t *testing.T) {
+	testBase(t, "tplSwitchComplex", expectSwitch, "switch (complex) tpl mismatch")
+}
+
 func TestTplLoopRange(t *testing.T) {
 	testBase(t, "tplLoopRange", expectLoopRange, "loop range tpl mismatch")
 }
@@ -341,6 +477,10 @@ func TestTplExit(t *testing.T) {
 	testBase(t, "tplExit", nil, "exit tpl mismatch")
 }
 
+func TestTplIndex(t *testing.T) {
+	testBase(t, "tplIndex", expectIndex, "index tpl mismatch")
+}
+
 func TestTplInclude(t *testing.T) {
 	testBase(t, "tplIncHost", expectTplInc, "include tpl mismatch")
 }
@@ -349,6 +489,256 @@ func TestTplIncludeJS(t *testing.T) {
 	testBase(t, "tplIncHostJS", expectTplIncJS, "include tpl (js) mismatch")
 }
 
+func TestTplIncludeArgs(t *testing.T) {
+	testBase(t, "tplIncArgHost", expectTplIncArg, "include tpl (args) mismatch")
+}
+
+func TestTplIncludeOnly(t *testing.T) {
+	testBase(t, "tplIncOnlyHost", expectTplIncOnly, "include tpl (only) mismatch")
+}
+
+func TestTplIncludeScope(t *testing.T) {
+	// Plain include shares variables with the parent, isolation is opt-in.
+	testBase(t, "tplIncScopeHost", expectTplIncScope, "include tpl (scope) mismatch")
+}
+
+func TestTplIncludeExit(t *testing.T) {
+	testBase(t, "tplIncExitHost", expectTplIncExit, "include tpl (exit) mismatch")
+}
+
+func TestTplIncludePartial(t *testing.T) {
+	pretest()
+
+	// Output of failed sub-template isn't discarded since it writes directly to the parent's writer.
+	ctx := NewCtx()
+	ctx.Set("user", user, &ins)
+	result, err := Render("tplIncPartHost", ctx)
+	if err != ErrTplNotFound {
+		t.Errorf("include error mismatch: %v", err)
+	}
+	if !bytes.Equal(result, expectTplIncPart) {
+		t.Errorf("include tpl (partial) mismatch\nexp: %s\ngot: %s", expectTplIncPart, result)
+	}
+}
+
+func BenchmarkTplCompile(b *testing.B) {
+	tree, _ := Parse(tplLoopHeavy, false)
+	walker := *tree
+	walker.prog = nil
+	expect := bytes.Repeat([]byte(`-old,+got refund,-maintenance;`), 10)
+
+	bench := func(b *testing.B, tpl *Tpl) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx := AcquireCtx()
+			ctx.Set("user", user, &ins)
+			buf.Reset()
+			if err := render(&buf, tpl, ctx); err != nil {
+				b.Error(err)
+			}
+			if !bytes.Equal(buf.Bytes(), expect) {
+				b.Errorf("loop heavy tpl mismatch\nexp: %s\ngot: %s", expect, buf.Bytes())
+			}
+			ReleaseCtx(ctx)
+		}
+	}
+	b.Run("vm", func(b *testing.B) {
+		bench(b, &Tpl{Id: "tplLoopHeavy", tree: tree, set: defaultSet})
+	})
+	b.Run("walker", func(b *testing.B) {
+		bench(b, &Tpl{Id: "tplLoopHeavy", tree: &walker, set: defaultSet})
+	})
+}
+
+func TestTplCompile(t *testing.T) {
+	pretest()
+
+	tpls := make([]*Tpl, 0, len(defaultSet.tpls())+1)
+	for _, tpl := range defaultSet.tpls() {
+		tpls = append(tpls, tpl)
+	}
+	// Errors of child nodes of the loop body don't prevent rendering of next child nodes.
+	tree, _ := Parse([]byte(`{% for i := 0; i < 2; i++ %}{% if i == 0 %}{% include nosuch %}x{% endif %}y{% endfor %}`), false)
+	tpls = append(tpls, &Tpl{Id: "tplLoopErr", tree: tree, set: defaultSet})
+
+	for _, tpl := range tpls {
+		// Render the same tree without compiled program using tree walker.
+		tree := *tpl.tree
+		tree.prog = nil
+		walker := Tpl{Id: tpl.Id, tree: &tree, set: tpl.set}
+
+		var bufP, bufW bytes.Buffer
+		ctx := NewCtx()
+		ctx.Set("user", user, &ins)
+		errP := render(&bufP, tpl, ctx)
+		ctx.Reset()
+		ctx.Set("user", user, &ins)
+		errW := render(&bufW, &walker, ctx)
+		if errP != errW || !bytes.Equal(bufP.Bytes(), bufW.Bytes()) {
+			t.Errorf("compiled tpl %s mismatch\nexp: %s (%v)\ngot: %s (%v)", tpl.Id, bufW.String(), errW, bufP.String(), errP)
+		}
+	}
+}
+
+func TestTplExtends(t *testing.T) {
+	testBase(t, "tplExtChild", expectExtChild, "extends tpl mismatch")
+}
+
+func TestTplExtendsNested(t *testing.T) {
+	testBase(t, "tplExtGrandchild", expectExtGrandchild, "extends (nested) tpl mismatch")
+}
+
+func TestTplExtendsUpdate(t *testing.T) {
+	pretest()
+
+	child, _ := Parse([]byte(`{% extends tplExtLayoutUpd %}{% block title %}User {%= user.Name %}{% endblock %}`), false)
+	RegisterTpl("tplExtChildUpd", child)
+	layout, _ := Parse([]byte(`<title>{% block title %}{% endblock %}</title>`), false)
+	RegisterTpl("tplExtLayoutUpd", layout)
+
+	ctx := NewCtx()
+	ctx.Set("user", user, &ins)
+	result, err := Render("tplExtChildUpd", ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(result, []byte(`<title>User John</title>`)) {
+		t.Error("extends tpl mismatch")
+	}
+
+	// Re-register the layout, child template should use new version.
+	layout, _ = Parse([]byte(`<h1>{% block title %}{% endblock %}</h1>`), false)
+	RegisterTpl("tplExtLayoutUpd", layout)
+	ctx.Reset()
+	ctx.Set("user", user, &ins)
+	result, err = Render("tplExtChildUpd", ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(result, []byte(`<h1>User John</h1>`)) {
+		t.Error("extends (updated layout) tpl mismatch")
+	}
+}
+
+func TestTplExtendsLoop(t *testing.T) {
+	tree, _ := Parse([]byte(`{% extends tplExtLoop %}`), false)
+	RegisterTpl("tplExtLoop", tree)
+	if _, err := Render("tplExtLoop", NewCtx()); err != ErrTplExtendsLoop {
+		t.Errorf("extends loop mismatch\nexp: %s\ngot: %s", ErrTplExtendsLoop, err)
+	}
+}
+
+func TestTplRevalidate(t *testing.T) {
+	tree, _ := Parse([]byte(`Hello {%= user.Name|testRevalidate() %}`), false)
+	RegisterTpl("tplRevalidate", tree)
+	var perr *ParseError
+	if errs := RevalidateTpl(); !errors.As(errs["tplRevalidate"], &perr) || perr.Code != ParseErrUnknownMod {
+		t.Errorf("revalidate error mismatch: %v", errs["tplRevalidate"])
+	}
+
+	RegisterModFn("testRevalidate", "", func(_ *Ctx, buf *interface{}, _ interface{}, _ []interface{}) error {
+		*buf = "revalidated"
+		return nil
+	})
+	if errs := RevalidateTpl(); errs["tplRevalidate"] != nil {
+		t.Error(errs["tplRevalidate"])
+	}
+	ctx := NewCtx()
+	ctx.Set("user", user, &ins)
+	result, err := Render("tplRevalidate", ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(result, []byte(`Hello revalidated`)) {
+		t.Errorf("revalidated tpl mismatch\nexp: Hello revalidated\ngot: %s", result)
+	}
+}
+
+func TestTplJSON(t *testing.T) {
+	pretest()
+
+	ctx := NewCtx()
+	if err := ctx.SetJSON("doc", docJSON); err != nil {
+		t.Fatal(err)
+	}
+	ctx.SetMap("env", map[string]string{"mode": "dev"})
+	result, err := Render("tplJSON", ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(result, expectJSON) {
+		t.Errorf("json tpl mismatch\nexp: %s\ngot: %s", expectJSON, result)
+	}
+}
+
+func TestTplMapLoop(t *testing.T) {
+	pretest()
+
+	ctx := NewCtx()
+	ctx.SetMap("doc", docMap)
+	result, err := Render("tplMapLoop", ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(result, expectMapLoop) {
+		t.Errorf("map loop tpl mismatch\nexp: %s\ngot: %s", expectMapLoop, result)
+	}
+}
+
+func TestTplReflect(t *testing.T) {
+	pretest()
+
+	stages := []struct {
+		id     string
+		expect []byte
+	}{
+		{"tplSimple", expectSimple},
+		{"tplCond", expectCond},
+		{"tplCondHlp", expectCondHlp},
+		{"tplCondComplex", expectCondComplex},
+		{"tplCondElif", expectCondElif},
+		{"tplSwitch", expectSwitch},
+		{"tplSwitchComplex", expectSwitch},
+		{"tplLoopRange", expectLoopRange},
+		{"tplIndex", expectIndex},
+		{"tplMacro", expectMacro},
+	}
+	for _, stage := range stages {
+		ctx := NewCtx()
+		// Nil inspector enables reflection.
+		ctx.Set("user", user, nil)
+		result, err := Render(stage.id, ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		if !bytes.Equal(result, stage.expect) {
+			t.Errorf("reflect %s mismatch\nexp: %s\ngot: %s", stage.id, stage.expect, result)
+		}
+	}
+}
+
+func TestTplReflectMapLoop(t *testing.T) {
+	pretest()
+
+	ctx := NewCtx()
+	ctx.Set("doc", docMapTyped, nil)
+	result, err := Render("tplMapLoop", ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(result, expectMapLoop) {
+		t.Errorf("reflect map loop tpl mismatch\nexp: %s\ngot: %s", expectMapLoop, result)
+	}
+}
+
+func TestTplMacro(t *testing.T) {
+	testBase(t, "tplMacro", expectMacro, "macro tpl mismatch")
+}
+
+func TestTplMacroArgs(t *testing.T) {
+	testBase(t, "tplMacroArgs", expectMacroArgs, "macro (quoted args) tpl mismatch")
+}
+
 func BenchmarkTplSimple(b *testing.B) {
 	benchBase(b, "tplSimple", expectSimple, "simple tpl mismatch")
 }
@@ -365,6 +755,14 @@ func BenchmarkTplCondHlp(b *testing.B) {
 	benchBase(b, "tplCondHlp", expectCondHlp, "cond (helper) tpl mismatch")
 }
 
+func BenchmarkTplCondComplex(b *testing.B) {
+	benchBase(b, "tplCondComplex", expectCondComplex, "cond (complex) tpl mismatch")
+}
+
+func BenchmarkTplCondElif(b *testing.B) {
+	benchBase(b, "tplCondElif", expectCondElif, "cond (else-if) tpl mismatch")
+}
+
 func BenchmarkTplSwitch(b *testing.B) {
 	benchBase(b, "tplSwitch", expectSwitch, "switch tpl mismatch")
 }
@@ -373,6 +771,10 @@ func BenchmarkTplSwitchNoCond(b *testing.B) {
 	benchBase(b, "tplSwitchNoCond", expectSwitch, "switch no cond tpl mismatch")
 }
 
+func BenchmarkTplSwitchComplex(b *testing.B) {
+	benchBase(b, "tplSwitchComplex", expectSwitch, "switch (complex) tpl mismatch")
+}
+
 func BenchmarkTplLoopRange(b *testing.B) {
 	benchBase(b, "tplLoopRange", expectLoopRange, "loop range tpl mismatch")
 }
@@ -426,10 +828,72 @@ func BenchmarkTplExit(b *testing.B) {
 	benchBase(b, "tplExit", nil, "exit tpl mismatch")
 }
 
+func BenchmarkTplIndex(b *testing.B) {
+	benchBase(b, "tplIndex", expectIndex, "index tpl mismatch")
+}
+
 func BenchmarkTplInclude(b *testing.B) {
 	benchBase(b, "tplIncHost", expectTplInc, "include tpl mismatch")
 }
 
+func BenchmarkTplExtends(b *testing.B) {
+	benchBase(b, "tplExtGrandchild", expectExtGrandchild, "extends (nested) tpl mismatch")
+}
+
+func BenchmarkTplMacro(b *testing.B) {
+	benchBase(b, "tplMacro", expectMacro, "macro tpl mismatch")
+}
+
+func BenchmarkTplMapLoop(b *testing.B) {
+	pretest()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ctx := AcquireCtx()
+		ctx.SetMap("doc", docMap)
+		buf.Reset()
+		err := RenderTo(&buf, "tplMapLoop", ctx)
+		if err != nil {
+			b.Error(err)
+		}
+		if !bytes.Equal(buf.Bytes(), expectMapLoop) {
+			b.Error("map loop tpl mismatch")
+		}
+		ReleaseCtx(ctx)
+	}
+}
+
+func BenchmarkTplReflectMapLoop(b *testing.B) {
+	pretest()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ctx := AcquireCtx()
+		ctx.Set("doc", docMapTyped, nil)
+		buf.Reset()
+		err := RenderTo(&buf, "tplMapLoop", ctx)
+		if err != nil {
+			b.Error(err)
+		}
+		if !bytes.Equal(buf.Bytes(), expectMapLoop) {
+			b.Error("reflect map loop tpl mismatch")
+		}
+		ReleaseCtx(ctx)
+	}
+}
+
 func BenchmarkTplIncludeJS(b *testing.B) {
 	benchBase(b, "tplIncHostJS", expectTplIncJS, "include tpl (js) mismatch")
 }
+
+func BenchmarkTplIncludeExit(b *testing.B) {
+	benchBase(b, "tplIncExitHost", expectTplIncExit, "include tpl (exit) mismatch")
+}
+
+func BenchmarkTplIncludeLarge(b *testing.B) {
+	benchBase(b, "tplIncLargeHost", expectTplIncLarge, "include tpl (large) mismatch")
+}
+
+func BenchmarkTplIncludeArgs(b *testing.B) {
+	benchBase(b, "tplIncArgHost", expectTplIncArg, "include tpl (args) mismatch")
+}
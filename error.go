@@ -5,11 +5,26 @@ import "errors"
 var (
 	ErrUnexpectedEOF = errors.New("unexpected end of file: control structure couldn't be closed")
 	ErrUnknownCtl    = errors.New("unknown ctl")
+	ErrBadCond       = errors.New("couldn't parse condition")
+	ErrStrayElif     = errors.New("unexpected elif outside of condition")
+	ErrElifAfterElse = errors.New("elif after else")
+	ErrBadLoop       = errors.New("couldn't parse loop control structure")
+	ErrBadCase       = errors.New("couldn't parse case condition")
+	ErrCaseComplex   = errors.New("complex case condition in switch with argument")
+	ErrUnbalancedCtl = errors.New("end of control structure that wasn't opened")
+	ErrUnclosedCtl   = errors.New("control structure isn't closed")
+	ErrStrayCtl      = errors.New("control structure outside of its parent")
+	ErrModNotFound   = errors.New("modifier not found")
 
 	ErrSenselessCond   = errors.New("comparison of two static args")
 	ErrCondHlpNotFound = errors.New("condition helper not found")
 
+	ErrUndefinedVar = errors.New("variable is never set")
+	ErrNotIterable  = errors.New("loop source isn't iterable")
+	ErrUnreachable  = errors.New("unreachable code after exit")
+
 	ErrTplNotFound = errors.New("template not found")
+	ErrTplNoVer    = errors.New("template version not found")
 	ErrInterrupt   = errors.New("tpl processing interrupted")
 	ErrEmptyArg    = errors.New("empty input param")
 	ErrModNoArgs   = errors.New("empty arguments list")
@@ -22,4 +37,18 @@ var (
 	ErrWrongLoopOp   = errors.New("wrong loop operation")
 	ErrBreakLoop     = errors.New("break loop")
 	ErrContLoop      = errors.New("continue loop")
+
+	ErrTplExtendsLoop = errors.New("template extends itself")
+
+	ErrMacroNotFound = errors.New("macro not found")
+	ErrMacroNoArg    = errors.New("macro argument isn't passed and has no default value")
+
+	ErrGenBadInput = errors.New("empty tree or name to generate code")
+	ErrGenExtends  = errors.New("code generation of templates with extends isn't supported")
+	ErrGenVar      = errors.New("variable of code generator has no name or value")
+	ErrGenType     = errors.New("types of generated variables belong to different packages with the same name")
+	ErrGenNode     = errors.New("node of generated code not found in template tree")
+
+	ErrTreeBinary = errors.New("malformed binary tree")
+	ErrTreeBinVer = errors.New("unsupported version of binary tree")
 )
@@ -0,0 +1,83 @@
+package dyntpl
+
+import (
+	"io"
+
+	"github.com/koykov/fastconv"
+)
+
+// Block that currently renders. Need to render parent's version of the block, see TypeSuper.
+type ctxBlock struct {
+	// Name of the block.
+	name []byte
+	// Level of inheritance chain to start search of parent's version.
+	lvl int
+	// Default content of the block and its template (the root template of inheritance chain).
+	base []Node
+	tpl  *Tpl
+}
+
+// Render template that extends other template.
+//
+// Collects the chain of templates from the given to the root template (that doesn't extend anything) and renders root
+// template. Blocks of root template will replaced with blocks of the most derived template in the chain.
+func renderExt(w io.Writer, tpl *Tpl, ctx *Ctx) (err error) {
+	var (
+		extO = ctx.extO
+		blk  = ctx.blk
+		o    = len(ctx.ext)
+	)
+	// Collect the chain using registry, so changes of parent templates applies immediately.
+	for len(tpl.tree.ext) > 0 {
+		for i := o; i < len(ctx.ext); i++ {
+			if ctx.ext[i] == tpl {
+				err = ErrTplExtendsLoop
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+		ctx.ext = append(ctx.ext, tpl)
+		if tpl = tpl.lookup(fastconv.B2S(tpl.tree.ext), ctx); tpl == nil {
+			err = ErrTplNotFound
+			break
+		}
+	}
+	if err == nil {
+		ctx.extO, ctx.blk = o, ctxBlock{}
+		err = tpl.exec(w, ctx)
+	}
+	// Release the chain.
+	for i := o; i < len(ctx.ext); i++ {
+		ctx.ext[i] = nil
+	}
+	ctx.ext, ctx.extO, ctx.blk = ctx.ext[:o], extO, blk
+	return
+}
+
+// Render block with given name.
+//
+// Look for the block in inheritance chain starting from level lvl and render the first found. If nothing found, the
+// base content will rendered.
+func (t *Tpl) renderBlock(w io.Writer, name []byte, lvl int, base []Node, ctx *Ctx) (err error) {
+	var (
+		blk   = ctx.blk
+		chain = ctx.ext[ctx.extO:]
+	)
+	for i := lvl; i < len(chain); i++ {
+		if node, ok := chain[i].tree.blocks[fastconv.B2S(name)]; ok {
+			ctx.blk = ctxBlock{name: name, lvl: i + 1, base: base, tpl: t}
+			err = chain[i].renderNodes(w, node.child, ctx)
+			ctx.blk = blk
+			return
+		}
+	}
+	if lvl <= len(chain) {
+		// Nothing found in the chain, render default content.
+		ctx.blk = ctxBlock{name: name, lvl: len(chain) + 1, base: base, tpl: t}
+		err = t.renderNodes(w, base, ctx)
+		ctx.blk = blk
+	}
+	return
+}
@@ -0,0 +1,886 @@
+package dyntpl
+
+import (
+	"bytes"
+	"go/format"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GenVar describes type of context variable for code generator.
+//
+// Generated code accesses fields of the variable directly instead of inspector, so the type must be the same as the
+// type of data passed to Ctx.Set() together with generated inspector, example:
+// dyntpl.GenVar{Name: "user", Val: (*testobj.TestObject)(nil)}
+type GenVar struct {
+	// Name of the variable in the template.
+	Name string
+	// Value of the variable, need only to take its type.
+	Val interface{}
+}
+
+// Code generator.
+//
+// Generator converts the template to Go code: raw text writes as is, conditions, switches and loops converts to Go
+// control flow and variables of known types (see GenVar) are accessed directly. Nodes that can't be converted
+// evaluates by dynamic renderer using GenNode, see Generate().
+type generator struct {
+	tree *Tree
+	name string
+	// Buffer of package variables.
+	vars bytes.Buffer
+	// Registry of declared package variables.
+	decl map[string]struct{}
+	// Imported packages (path to name) and Go names of template variables.
+	imp, ident map[string]string
+	// Raw text must be written by dynamic renderer, since template has escape modes.
+	esc bool
+	// Names of variables with free references, assigned by context nodes, bound by loops and by nested loops.
+	free, asgn, bound, rebound map[string]struct{}
+	err                        error
+}
+
+// Kind of the loop that contains generated code.
+type genLoop int
+
+const (
+	genLoopNone genLoop = iota
+	// Native Go loop.
+	genLoopNative
+	// Loop iterated by inspector, body is a generated function, see GenNode.Loop().
+	genLoopFn
+)
+
+// State of control flow of generated code, defines how to handle errors, break and continue nodes.
+//
+// Errors of child nodes of loop body don't interrupt the loop (like dynamic renderer does), so error of the node
+// nested in the child node (e.g. in condition branch) skips the rest of the child node using the label.
+type genCtl struct {
+	loop   genLoop
+	nested bool
+	label  string
+	jump   *bool
+}
+
+// Generate Go source code of the template tree.
+//
+// Generated code contains exported function Render<name>(w io.Writer, ctx *dyntpl.Ctx) error that renders the template
+// like dynamic renderer does. Variables vars describes types of context variables to access them directly, if the
+// context contains variable of other type (or doesn't contain it) then template renders dynamically. Nil pointers,
+// out of range indexes are considered as empty values.
+//
+// Raw text, printing, conditions, switches and loops converts to Go code. The following evaluates by dynamic renderer
+// using GenNode:
+// * raw text of templates with escape modes (jsonquote, htmlescape, urlencode) and the mode nodes
+// * printing with modifiers, printing of variables of unknown or named types, maps and structs
+// * conditions and cases with helpers, comparisons of two variables and of variables of unknown or named types (only
+// the condition evaluates dynamically, branches are generated)
+// * ctx, counter, include, block, parent and macro call nodes
+// * range loops over maps and over variables of unknown types, loops with bodies that contain nodes from this list and
+// loops with variables referenced outside of the loop or assigned by ctx and counter nodes (loop iterates dynamically,
+// body is generated)
+// Templates that extends other templates isn't supported.
+func Generate(w io.Writer, tree *Tree, pkg, name string, vars ...GenVar) error {
+	if tree == nil || len(name) == 0 {
+		return ErrGenBadInput
+	}
+	if len(tree.ext) > 0 {
+		return ErrGenExtends
+	}
+	g := generator{
+		tree:    tree,
+		name:    name,
+		decl:    make(map[string]struct{}),
+		imp:     map[string]string{"io": "io", "github.com/koykov/dyntpl": "dyntpl"},
+		ident:   make(map[string]string),
+		esc:     hasModes(tree.nodes),
+		free:    make(map[string]struct{}),
+		asgn:    make(map[string]struct{}),
+		bound:   make(map[string]struct{}),
+		rebound: make(map[string]struct{}),
+	}
+	g.scan(tree.nodes, nil)
+
+	// Variables keep their values during rendering, unless they are assigned or bound by loops.
+	sc := make(genScope, len(vars))
+	roots := make([]*genVar, len(vars))
+	for i, v := range vars {
+		if len(v.Name) == 0 || v.Val == nil {
+			return ErrGenVar
+		}
+		if _, ok := g.asgn[v.Name]; ok || !isIdent([]byte(v.Name)) {
+			continue
+		}
+		if _, ok := g.bound[v.Name]; ok {
+			continue
+		}
+		typ := reflect.TypeOf(v.Val)
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		roots[i] = &genVar{expr: g.goName([]byte(v.Name)), typ: reflect.PtrTo(typ)}
+		sc[v.Name] = roots[i]
+	}
+	var body bytes.Buffer
+	if !g.writeNodes(&body, tree.nodes, nil, sc, genCtl{}) {
+		body.WriteString("return nil\n")
+	}
+	var fn bytes.Buffer
+	fn.WriteString("\nfunc " + g.fnName(nil) + "(w io.Writer, ctx *dyntpl.Ctx) error {\n")
+	for i, v := range roots {
+		if v != nil && v.used {
+			g.writeFetch(&fn, vars[i].Name, v, "return tpl"+name+".GenFallback(w, ctx)\n")
+		}
+	}
+	fn.Write(body.Bytes())
+	fn.WriteString("}\n")
+	if g.err != nil {
+		return g.err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by dyntpl. DO NOT EDIT.\n\n")
+	buf.WriteString("package " + pkg + "\n\n")
+	g.writeImports(&buf)
+	buf.WriteString("var (\n")
+	buf.WriteString("tpl" + name + " = dyntpl.MustGenTpl(" + strconv.Quote(name) + ", []byte(" + quoteSrc(tree.src) +
+		"), " + strconv.FormatBool(tree.keepFmt) + ")\n")
+	buf.Write(g.vars.Bytes())
+	buf.WriteString(")\n\n")
+	buf.WriteString("// Render" + name + " renders template " + name + " to the writer.\n")
+	buf.WriteString("func Render" + name + "(w io.Writer, ctx *dyntpl.Ctx) error {\n")
+	buf.WriteString("return tpl" + name + ".GenRender(w, ctx, " + g.fnName(nil) + ")\n}\n")
+	buf.Write(fn.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// Write statements of the list of nodes.
+//
+// Returns true if the list ends with terminating statement, so the code after it is unreachable.
+func (g *generator) writeNodes(buf *bytes.Buffer, nodes []Node, idx []int, sc genScope, ctl genCtl) bool {
+	for i := 0; i < len(nodes); i++ {
+		if g.writeNode(buf, &nodes[i], g.child(idx, i), sc, ctl) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write statements of the loop body.
+//
+// Each child node of the body has own label to skip the rest of the node on error, see genCtl.
+//
+// Returns true if the body ends with terminating statement, see writeNodes().
+func (g *generator) writeBody(buf *bytes.Buffer, nodes []Node, idx []int, sc genScope, loop genLoop) bool {
+	for i := 0; i < len(nodes); i++ {
+		var jump bool
+		cidx := g.child(idx, i)
+		ctl := genCtl{loop: loop, label: "skip" + g.name + g.suffix(cidx), jump: &jump}
+		term := g.writeNode(buf, &nodes[i], cidx, sc, ctl)
+		if jump {
+			buf.WriteString(ctl.label + ":\n")
+		}
+		if term {
+			return true
+		}
+	}
+	return false
+}
+
+// Write statements of the node.
+func (g *generator) writeNode(buf *bytes.Buffer, node *Node, idx []int, sc genScope, ctl genCtl) bool {
+	switch node.typ {
+	case TypeRaw:
+		if g.esc {
+			break
+		}
+		g.writeWrite(buf, g.bytesVar("r", idx, node.raw), ctl)
+		return false
+	case TypeTpl:
+		if len(node.mod) > 0 {
+			break
+		}
+		if ref, ok := g.resolve(node.rawPath, sc); ok && g.printable(ref) {
+			g.writePrint(buf, node, idx, ref, ctl)
+			return false
+		}
+	case TypeCond:
+		return g.writeCond(buf, node, idx, sc, ctl)
+	case TypeSwitch:
+		return g.writeSwitch(buf, node, idx, sc, ctl)
+	case TypeLoopRange:
+		g.writeRange(buf, node, idx, sc, ctl)
+		return false
+	case TypeLoopCount:
+		g.writeCount(buf, node, idx, sc, ctl)
+		return false
+	case TypeBreak:
+		buf.WriteString(ctl.loopCtl(false))
+		return true
+	case TypeContinue:
+		buf.WriteString(ctl.loopCtl(true))
+		return true
+	case TypeExit:
+		f := ctl.fail("dyntpl.ErrInterrupt")
+		buf.WriteString(f)
+		return len(f) > 0
+	case TypeMacro, TypeExtends:
+		// Nodes renders nothing.
+		return false
+	}
+	g.writeFallback(buf, g.nodeVar(idx)+".Render(w, ctx)", ctl)
+	return false
+}
+
+// Write raw bytes of the variable.
+func (g *generator) writeWrite(buf *bytes.Buffer, v string, ctl genCtl) {
+	if f := ctl.fail("err"); len(f) > 0 {
+		buf.WriteString("if _, err := w.Write(" + v + "); err != nil {\n" + f + "}\n")
+	} else {
+		buf.WriteString("_, _ = w.Write(" + v + ")\n")
+	}
+}
+
+// Write printing of typed value.
+//
+// Value converts to bytes the same way as x2bytes does for dynamic templates, floats converts by x2bytes itself.
+func (g *generator) writePrint(buf *bytes.Buffer, node *Node, idx []int, ref genRef, ctl genCtl) {
+	g.use(ref.vars)
+	guard := ref.cond()
+	if len(guard) > 0 {
+		buf.WriteString("if " + guard + " {\n")
+	}
+	if len(node.prefix) > 0 {
+		buf.WriteString("_, _ = w.Write(" + g.bytesVar("p", idx, node.prefix) + ")\n")
+	}
+	v := "ctx.Buf"
+	switch k := ref.typ.Kind(); {
+	case ref.typ == bytesType:
+		v = ref.expr
+	case k == reflect.String:
+		buf.WriteString("ctx.Buf = append(ctx.Buf[:0], " + ref.expr + "...)\n")
+	case k == reflect.Bool:
+		g.imp["strconv"] = "strconv"
+		buf.WriteString("ctx.Buf = strconv.AppendBool(ctx.Buf[:0], " + ref.expr + ")\n")
+	case isInt(k):
+		g.imp["strconv"] = "strconv"
+		buf.WriteString("ctx.Buf = strconv.AppendInt(ctx.Buf[:0], " + conv(ref, reflect.Int64) + ", 10)\n")
+	case isUint(k):
+		g.imp["strconv"] = "strconv"
+		buf.WriteString("ctx.Buf = strconv.AppendUint(ctx.Buf[:0], " + conv(ref, reflect.Uint64) + ", 10)\n")
+	default:
+		g.imp["github.com/koykov/x2bytes"] = "x2bytes"
+		buf.WriteString("ctx.Buf, _ = x2bytes.ToBytesWR(ctx.Buf[:0], &" + ref.expr + ")\n")
+	}
+	g.writeWrite(buf, v, ctl)
+	if len(node.suffix) > 0 {
+		buf.WriteString("_, _ = w.Write(" + g.bytesVar("s", idx, node.suffix) + ")\n")
+	}
+	if len(guard) > 0 {
+		if f := ctl.fail("dyntpl.ErrEmptyArg"); len(f) > 0 {
+			buf.WriteString("} else {\n" + f)
+		}
+		buf.WriteString("}\n")
+	}
+}
+
+// Write the condition node.
+func (g *generator) writeCond(buf *bytes.Buffer, node *Node, idx []int, sc genScope, ctl genCtl) bool {
+	if len(node.child) == 0 {
+		// Condition must be evaluated anyway to catch errors.
+		if _, _, ok := g.cond(node, sc); !ok {
+			g.writeCall(buf, "_, err := "+g.nodeVar(idx)+".Cond(ctx)", ctl)
+		}
+		return false
+	}
+	var (
+		bctl = ctl
+		term = true
+		els  bool
+	)
+	bctl.nested = true
+	for i := 0; i < len(node.child); i++ {
+		ch := &node.child[i]
+		cidx := g.child(idx, i)
+		if ch.typ == TypeCondFalse {
+			buf.WriteString("} else {\n")
+			term = g.writeNodes(buf, ch.child, cidx, sc, bctl) && term
+			els = true
+			break
+		}
+		cn, nidx := node, idx
+		if ch.typ == TypeCondElif {
+			cn, nidx = ch, cidx
+		}
+		g.writeIf(buf, i > 0, func() (string, []*genVar, bool) { return g.cond(cn, sc) }, nidx, ".Cond(ctx)",
+			ctl)
+		term = g.writeNodes(buf, ch.child, cidx, sc, bctl) && term
+	}
+	buf.WriteString("}\n")
+	return term && els
+}
+
+// Write the switch node.
+//
+// Cases are checked in order of appearance, default case (wherever it placed) follows them.
+func (g *generator) writeSwitch(buf *bytes.Buffer, node *Node, idx []int, sc genScope, ctl genCtl) bool {
+	var (
+		bctl = ctl
+		term = true
+		n    int
+		dflt = -1
+	)
+	bctl.nested = true
+	for i := 0; i < len(node.child); i++ {
+		ch := &node.child[i]
+		if ch.typ != TypeCase {
+			if ch.typ == TypeDefault && dflt == -1 {
+				dflt = i
+			}
+			continue
+		}
+		cidx := g.child(idx, i)
+		g.writeIf(buf, n > 0, func() (string, []*genVar, bool) { return g.caseCond(node, ch, sc) }, cidx,
+			".Case(ctx, "+g.nodeVar(idx)+")", ctl)
+		term = g.writeNodes(buf, ch.child, cidx, sc, bctl) && term
+		n++
+	}
+	if dflt != -1 {
+		if n > 0 {
+			buf.WriteString("} else {\n")
+		}
+		term = g.writeNodes(buf, node.child[dflt].child, g.child(idx, dflt), sc, bctl) && term
+	}
+	if n > 0 {
+		buf.WriteString("}\n")
+	}
+	return term && dflt != -1
+}
+
+// Write head of the branch of condition or switch.
+//
+// Typed condition is preferred, otherwise condition evaluates dynamically by the node method.
+func (g *generator) writeIf(buf *bytes.Buffer, els bool, cond func() (string, []*genVar, bool), idx []int,
+	method string, ctl genCtl) {
+	if els {
+		buf.WriteString("} else ")
+	}
+	if expr, vars, ok := cond(); ok {
+		g.use(vars)
+		buf.WriteString("if " + expr + " {\n")
+		return
+	}
+	buf.WriteString("if r, err := " + g.nodeVar(idx) + method + "; err != nil {\n")
+	if f := ctl.fail("err"); len(f) > 0 {
+		buf.WriteString(f)
+	} else {
+		buf.WriteString("// Error skips the node.\n")
+	}
+	buf.WriteString("} else if r {\n")
+}
+
+// Write the range loop node.
+//
+// Loop over slice or array of known type converts to native Go loop if its body may be converted entirely, otherwise
+// loop iterates by inspector and calls generated function.
+func (g *generator) writeRange(buf *bytes.Buffer, node *Node, idx []int, sc genScope, ctl genCtl) {
+	src, k, v, bsc, ok := g.nativeRange(node, idx, sc)
+	if ok && g.lowered(node.child, idx, bsc) {
+		var body bytes.Buffer
+		g.writeBody(&body, node.child, idx, bsc, genLoopNative)
+		g.use(src.vars)
+		guard := src.cond()
+		if len(guard) > 0 {
+			buf.WriteString("if " + guard + " {\n")
+		}
+		if k.used || v.used || len(node.loopSep) > 0 {
+			buf.WriteString("for " + k.expr + " := range " + src.expr + " {\n")
+		} else {
+			buf.WriteString("for range " + src.expr + " {\n")
+		}
+		if v.used {
+			amp := "&"
+			if !v.nonNil {
+				amp = ""
+			}
+			buf.WriteString(v.expr + " := " + amp + src.expr + "[" + k.expr + "]\n")
+		}
+		if len(node.loopSep) > 0 {
+			buf.WriteString("if " + k.expr + " > 0 {\n_, _ = w.Write(" + g.bytesVar("l", idx, node.loopSep) + ")\n}\n")
+		}
+		buf.Write(body.Bytes())
+		buf.WriteString("}\n")
+		if len(guard) > 0 {
+			buf.WriteString("}\n")
+		}
+		return
+	}
+
+	// Key of the loop is always untyped, it hides variable of outer scope. Value is typed if the source is typed
+	// slice or array, inspector passes pointers to the items.
+	bsc, v = sc, &genVar{}
+	if len(node.loopKey) > 0 {
+		bsc = bsc.with(node.loopKey, &genVar{})
+	}
+	if src, ok = g.resolve(node.loopSrcPath, sc); ok && g.loopVar(node.loopVal) {
+		switch src.typ.Kind() {
+		case reflect.Slice, reflect.Array:
+			v = &genVar{expr: g.goName(node.loopVal), typ: reflect.PtrTo(src.typ.Elem())}
+		}
+	}
+	if len(node.loopVal) > 0 {
+		bsc = bsc.with(node.loopVal, v)
+	}
+	g.writeLoopFn(buf, node, idx, bsc, node.loopVal, v, ctl)
+}
+
+// Write the counter loop node.
+//
+// Loop with static or typed bounds converts to native Go loop if its body may be converted entirely, otherwise loop
+// iterates dynamically and calls generated function.
+func (g *generator) writeCount(buf *bytes.Buffer, node *Node, idx []int, sc genScope, ctl genCtl) {
+	init, lim, c, bsc, ok := g.nativeCount(node, sc)
+	if ok && g.lowered(node.child, idx, bsc) {
+		var body bytes.Buffer
+		g.writeBody(&body, node.child, idx, bsc, genLoopNative)
+		cond := c.expr + " " + node.loopCondOp.String() + " " + lim
+		op := "+"
+		if node.loopCntOp == OpDec {
+			op = "-"
+		}
+		if len(node.loopSep) > 0 {
+			n := "c" + g.name + g.suffix(idx)
+			buf.WriteString("for " + c.expr + ", " + n + " := " + init + ", 0; " + cond + "; " + c.expr + ", " + n +
+				" = " + c.expr + op + "1, " + n + "+1 {\n")
+			buf.WriteString("if " + n + " > 0 {\n_, _ = w.Write(" + g.bytesVar("l", idx, node.loopSep) + ")\n}\n")
+		} else {
+			buf.WriteString("for " + c.expr + " := " + init + "; " + cond + "; " + c.expr + op + op + " {\n")
+		}
+		buf.Write(body.Bytes())
+		buf.WriteString("}\n")
+		return
+	}
+
+	// Counter is a pointer to int64, see Ctx.cloop().
+	c = &genVar{}
+	if g.loopVar(node.loopCnt) {
+		c = &genVar{expr: g.goName(node.loopCnt), typ: reflect.PtrTo(int64Type)}
+	}
+	g.writeLoopFn(buf, node, idx, sc.with(node.loopCnt, c), node.loopCnt, c, ctl)
+}
+
+// Write the loop that iterates dynamically and calls generated function on each iteration.
+//
+// Typed variable v of the loop takes from the context on each iteration.
+func (g *generator) writeLoopFn(buf *bytes.Buffer, node *Node, idx []int, sc genScope, name []byte, v *genVar,
+	ctl genCtl) {
+	var body bytes.Buffer
+	if !g.writeBody(&body, node.child, idx, sc, genLoopFn) {
+		body.WriteString("return nil\n")
+	}
+	var fn bytes.Buffer
+	fn.WriteString("func(w io.Writer, ctx *dyntpl.Ctx) error {\n")
+	if v.used {
+		g.writeFetch(&fn, string(name), v, "")
+	}
+	fn.Write(body.Bytes())
+	fn.WriteString("}")
+	g.writeCall(buf, "err := "+g.nodeVar(idx)+".Loop(w, ctx, "+fn.String()+")", ctl)
+}
+
+// Write getting of typed variable from the context.
+//
+// Variable takes by pointer or by value, like generated inspectors do. Code dflt executes if context contains variable
+// of other type.
+func (g *generator) writeFetch(buf *bytes.Buffer, name string, v *genVar, dflt string) {
+	typ := g.typeName(v.typ.Elem())
+	buf.WriteString("var " + v.expr + " *" + typ + "\n")
+	buf.WriteString("switch x := ctx.GenVar(" + strconv.Quote(name) + ").(type) {\n")
+	buf.WriteString("case *" + typ + ":\n" + v.expr + " = x\n")
+	buf.WriteString("case " + typ + ":\n" + v.expr + " = &x\n")
+	if len(dflt) > 0 {
+		buf.WriteString("default:\n" + dflt)
+	}
+	buf.WriteString("}\n")
+}
+
+// Write call of dynamic renderer.
+//
+// Errors of break and continue nodes pass to the loop.
+func (g *generator) writeFallback(buf *bytes.Buffer, call string, ctl genCtl) {
+	f := ctl.fail("err")
+	switch ctl.loop {
+	case genLoopNative:
+		buf.WriteString("if err := " + call + "; err == dyntpl.ErrBreakLoop {\nbreak\n} else if err == dyntpl.ErrContLoop {\ncontinue\n}")
+	case genLoopFn:
+		buf.WriteString("if err := " + call + "; err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {\nreturn err\n}")
+	default:
+		buf.WriteString("if err := " + call + "; err != nil {\n" + f + "}\n")
+		return
+	}
+	if len(f) > 0 {
+		buf.WriteString(" else if err != nil {\n" + f + "}")
+	}
+	buf.WriteString("\n")
+}
+
+// Write call that returns only error.
+func (g *generator) writeCall(buf *bytes.Buffer, call string, ctl genCtl) {
+	if f := ctl.fail("err"); len(f) > 0 {
+		buf.WriteString("if " + call + "; err != nil {\n" + f + "}\n")
+		return
+	}
+	buf.WriteString(strings.Replace(call, "err :=", "_ =", 1) + "\n")
+}
+
+// Write imports of generated code, standard packages goes first.
+func (g *generator) writeImports(buf *bytes.Buffer) {
+	var std, ext []string
+	for p, name := range g.imp {
+		s := strconv.Quote(p)
+		if path.Base(p) != name {
+			s = name + " " + s
+		}
+		if strings.Contains(strings.SplitN(p, "/", 2)[0], ".") {
+			ext = append(ext, s)
+		} else {
+			std = append(std, s)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(ext)
+	buf.WriteString("import (\n" + strings.Join(std, "\n") + "\n\n" + strings.Join(ext, "\n") + "\n)\n\n")
+}
+
+// Check if nodes may be converted to Go code entirely, without dynamic renderer.
+func (g *generator) lowered(nodes []Node, idx []int, sc genScope) bool {
+	for i := 0; i < len(nodes); i++ {
+		node := &nodes[i]
+		cidx := g.child(idx, i)
+		switch node.typ {
+		case TypeRaw:
+			if g.esc {
+				return false
+			}
+		case TypeTpl:
+			if len(node.mod) > 0 {
+				return false
+			}
+			if ref, ok := g.resolve(node.rawPath, sc); !ok || !g.printable(ref) {
+				return false
+			}
+		case TypeCond:
+			if _, _, ok := g.cond(node, sc); !ok {
+				return false
+			}
+			for j := 0; j < len(node.child); j++ {
+				ch := &node.child[j]
+				if ch.typ == TypeCondElif {
+					if _, _, ok := g.cond(ch, sc); !ok {
+						return false
+					}
+				}
+				if !g.lowered(ch.child, g.child(cidx, j), sc) {
+					return false
+				}
+			}
+		case TypeSwitch:
+			for j := 0; j < len(node.child); j++ {
+				ch := &node.child[j]
+				if ch.typ == TypeCase {
+					if _, _, ok := g.caseCond(node, ch, sc); !ok {
+						return false
+					}
+				}
+				if !g.lowered(ch.child, g.child(cidx, j), sc) {
+					return false
+				}
+			}
+		case TypeLoopRange:
+			if _, _, _, bsc, ok := g.nativeRange(node, cidx, sc); !ok || !g.lowered(node.child, cidx, bsc) {
+				return false
+			}
+		case TypeLoopCount:
+			if _, _, _, bsc, ok := g.nativeCount(node, sc); !ok || !g.lowered(node.child, cidx, bsc) {
+				return false
+			}
+		case TypeBreak, TypeContinue, TypeExit, TypeMacro, TypeExtends:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Prepare native range loop: typed source, variables and scope of the body.
+//
+// Source must be a slice or array and variables of the loop must not be referenced outside of the loop.
+func (g *generator) nativeRange(node *Node, idx []int, sc genScope) (src genRef, k, v *genVar, bsc genScope, ok bool) {
+	if src, ok = g.resolve(node.loopSrcPath, sc); !ok {
+		return
+	}
+	if kind := src.typ.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return src, nil, nil, nil, false
+	}
+	if !g.nativeVar(node.loopKey, sc) || !g.nativeVar(node.loopVal, sc) {
+		return src, nil, nil, nil, false
+	}
+	k = &genVar{expr: "k" + g.name + g.suffix(idx), typ: intType, nonNil: true, key: true}
+	v = &genVar{typ: reflect.PtrTo(src.typ.Elem()), nonNil: true}
+	if elem := src.typ.Elem(); elem.Kind() == reflect.Ptr {
+		v.typ, v.nonNil = elem, false
+	}
+	bsc = sc
+	if len(node.loopKey) > 0 {
+		k.expr = g.goName(node.loopKey)
+		bsc = bsc.with(node.loopKey, k)
+	}
+	if len(node.loopVal) > 0 {
+		v.expr = g.goName(node.loopVal)
+		bsc = bsc.with(node.loopVal, v)
+	}
+	return src, k, v, bsc, true
+}
+
+// Prepare native counter loop: bounds, counter variable and scope of the body.
+func (g *generator) nativeCount(node *Node, sc genScope) (init, lim string, c *genVar, bsc genScope, ok bool) {
+	if !g.nativeVar(node.loopCnt, sc) || len(node.loopCnt) == 0 {
+		return
+	}
+	if node.loopCntOp != OpInc && node.loopCntOp != OpDec {
+		return
+	}
+	switch node.loopCondOp {
+	case OpEq, OpNq, OpGt, OpGtq, OpLt, OpLtq:
+	default:
+		return
+	}
+	if init, ok = g.countBound(node.loopCntStatic, node.loopCntInit, node.loopCntPath, sc); !ok {
+		return
+	}
+	if lim, ok = g.countBound(node.loopLimStatic, node.loopLim, node.loopLimPath, sc); !ok {
+		return
+	}
+	c = &genVar{expr: g.goName(node.loopCnt), typ: int64Type, nonNil: true}
+	return "int64(" + init + ")", lim, c, sc.with(node.loopCnt, c), true
+}
+
+// Get bound of counter loop: static integer or typed integer variable that can't be empty.
+func (g *generator) countBound(static bool, raw []byte, p *vpath, sc genScope) (string, bool) {
+	if static {
+		i, err := strconv.ParseInt(string(raw), 0, 0)
+		return strconv.FormatInt(i, 10), err == nil
+	}
+	ref, ok := g.resolve(p, sc)
+	if !ok || len(ref.guard) > 0 || ref.key || !isBuiltin(ref.typ) || (!isInt(ref.typ.Kind()) && !isUint(ref.typ.Kind())) {
+		return "", false
+	}
+	g.use(ref.vars)
+	return conv(ref, reflect.Int64), true
+}
+
+// Check if variable of native loop may be typed.
+//
+// Native loop doesn't set variables to the context, so they must not be referenced outside of the loop.
+func (g *generator) nativeVar(name []byte, sc genScope) bool {
+	if len(name) == 0 {
+		return true
+	}
+	if _, ok := g.free[string(name)]; ok {
+		return false
+	}
+	if _, ok := sc[string(name)]; ok {
+		return false
+	}
+	return g.loopVar(name)
+}
+
+// Check if variable of the loop may be typed.
+func (g *generator) loopVar(name []byte) bool {
+	if !isIdent(name) {
+		return false
+	}
+	if _, ok := g.asgn[string(name)]; ok {
+		return false
+	}
+	_, ok := g.rebound[string(name)]
+	return !ok
+}
+
+// Mark variables as used.
+func (g *generator) use(vars []*genVar) {
+	for _, v := range vars {
+		v.used = true
+	}
+}
+
+// Get Go name of the template variable.
+func (g *generator) goName(name []byte) string {
+	if s, ok := g.ident[string(name)]; ok {
+		return s
+	}
+	s := "v" + strings.ToUpper(string(name[:1])) + string(name[1:])
+	for i := 1; g.hasIdent(s); i++ {
+		s = "v" + strings.ToUpper(string(name[:1])) + string(name[1:]) + strconv.Itoa(i)
+	}
+	g.ident[string(name)] = s
+	return s
+}
+
+// Check if Go name is already used.
+func (g *generator) hasIdent(s string) bool {
+	for _, x := range g.ident {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Get Go name of the type and import its package.
+func (g *generator) typeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return "*" + g.typeName(typ.Elem())
+	case reflect.Slice:
+		if len(typ.Name()) == 0 {
+			return "[]" + g.typeName(typ.Elem())
+		}
+	case reflect.Array:
+		if len(typ.Name()) == 0 {
+			return "[" + strconv.Itoa(typ.Len()) + "]" + g.typeName(typ.Elem())
+		}
+	case reflect.Map:
+		if len(typ.Name()) == 0 {
+			return "map[" + g.typeName(typ.Key()) + "]" + g.typeName(typ.Elem())
+		}
+	}
+	if p := typ.PkgPath(); len(p) > 0 {
+		s := typ.String()
+		name := s[:strings.IndexByte(s, '.')]
+		for p1, name1 := range g.imp {
+			if name1 == name && p1 != p {
+				g.err = ErrGenType
+			}
+		}
+		g.imp[p] = name
+		return s
+	}
+	return typ.String()
+}
+
+// Get name of the node variable and declare it.
+func (g *generator) nodeVar(idx []int) string {
+	name := "n" + g.name + g.suffix(idx)
+	if _, ok := g.decl[name]; !ok {
+		g.decl[name] = struct{}{}
+		g.vars.WriteString(name + " = tpl" + g.name + ".GenNode(" + g.join(idx, ", ") + ")\n")
+	}
+	return name
+}
+
+// Get name of the bytes variable of the node and declare it.
+func (g *generator) bytesVar(prefix string, idx []int, b []byte) string {
+	name := prefix + g.name + g.suffix(idx)
+	if _, ok := g.decl[name]; !ok {
+		g.decl[name] = struct{}{}
+		g.vars.WriteString(name + " = []byte(" + quoteSrc(b) + ")\n")
+	}
+	return name
+}
+
+// Get name of the function that renders the node.
+func (g *generator) fnName(idx []int) string {
+	return "render" + g.name + g.suffix(idx)
+}
+
+// Make indexes of child node.
+func (g *generator) child(idx []int, i int) []int {
+	return append(append(make([]int, 0, len(idx)+1), idx...), i)
+}
+
+// Make unique suffix of the node using indexes in the tree.
+func (g *generator) suffix(idx []int) string {
+	if len(idx) == 0 {
+		return ""
+	}
+	return "N" + g.join(idx, "_")
+}
+
+// Join indexes using separator.
+func (g *generator) join(idx []int, sep string) string {
+	s := make([]string, 0, len(idx))
+	for _, i := range idx {
+		s = append(s, strconv.Itoa(i))
+	}
+	return strings.Join(s, sep)
+}
+
+// Make error handling statement for error err.
+//
+// Outside of loops error interrupts rendering. Error of child node of loop body is ignored, error of nested node skips
+// the rest of child node.
+func (c genCtl) fail(err string) string {
+	if c.loop == genLoopNone {
+		return "return " + err + "\n"
+	}
+	if !c.nested {
+		return ""
+	}
+	*c.jump = true
+	return "goto " + c.label + "\n"
+}
+
+// Make statement of break or continue node.
+func (c genCtl) loopCtl(cont bool) string {
+	if c.loop == genLoopNative {
+		if cont {
+			return "continue\n"
+		}
+		return "break\n"
+	}
+	if cont {
+		return "return dyntpl.ErrContLoop\n"
+	}
+	return "return dyntpl.ErrBreakLoop\n"
+}
+
+// Convert integer expression to the type of kind.
+func conv(ref genRef, kind reflect.Kind) string {
+	if ref.typ.Kind() == kind {
+		return ref.expr
+	}
+	return strings.ToLower(kind.String()) + "(" + ref.expr + ")"
+}
+
+// Check if nodes contain escape mode nodes.
+func hasModes(nodes []Node) bool {
+	for i := 0; i < len(nodes); i++ {
+		switch nodes[i].typ {
+		case TypeJsonQ, TypeHtmlE, TypeUrlEnc:
+			return true
+		}
+		if hasModes(nodes[i].child) {
+			return true
+		}
+	}
+	return false
+}
+
+// Quote template source to Go string literal, raw string literal is preferred.
+func quoteSrc(src []byte) string {
+	if bytes.IndexByte(src, '`') == -1 && bytes.IndexByte(src, '\r') == -1 {
+		return "`" + string(src) + "`"
+	}
+	return strconv.Quote(string(src))
+}
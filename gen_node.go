@@ -0,0 +1,147 @@
+package dyntpl
+
+import (
+	"io"
+	"sync"
+)
+
+// GenFn is a signature of functions produced by code generator, see Generate().
+type GenFn func(w io.Writer, ctx *Ctx) error
+
+// GenNode is a node of the template that uses by generated code.
+//
+// Generated code evaluates nodes that it can't convert to Go code using this type, so the output is the same as output
+// of dynamic template, see Generate().
+type GenNode struct {
+	tpl  *Tpl
+	idx  []int
+	node *Node
+}
+
+// Source of the template for generated code, parses once on the first render.
+type genSrc struct {
+	once    sync.Once
+	src     []byte
+	keepFmt bool
+	// Nodes requested by generated code before the parsing.
+	nodes []*GenNode
+	err   error
+}
+
+// Make template for generated code.
+//
+// Source parses in strict mode on the first GenRender() call, so modifiers and condition helpers registered after
+// package variables initialization (e.g. in init functions) are available to the template. Parse error returns by
+// GenRender(). Template belongs to the default set.
+func MustGenTpl(id string, src []byte, keepFmt bool) *Tpl {
+	return &Tpl{Id: id, set: defaultSet, gen: &genSrc{src: src, keepFmt: keepFmt}}
+}
+
+// Get node by the list of indexes in the tree.
+//
+// Node resolves after the parsing of template source, see MustGenTpl().
+func (t *Tpl) GenNode(idx ...int) *GenNode {
+	n := &GenNode{tpl: t, idx: idx}
+	if t.gen != nil {
+		t.gen.nodes = append(t.gen.nodes, n)
+		return n
+	}
+	if n.node = t.tree.node(idx); n.node == nil {
+		return nil
+	}
+	return n
+}
+
+// Parse template source and resolve requested nodes.
+func (t *Tpl) genParse() {
+	g := t.gen
+	if t.tree, g.err = ParseStrict(g.src, g.keepFmt); g.err != nil {
+		return
+	}
+	for _, n := range g.nodes {
+		if n.node = t.tree.node(n.idx); n.node == nil {
+			g.err = ErrGenNode
+			return
+		}
+	}
+}
+
+// Get node by the list of indexes.
+func (t *Tree) node(idx []int) *Node {
+	nodes := t.nodes
+	var node *Node
+	for _, i := range idx {
+		if i >= len(nodes) {
+			return nil
+		}
+		node = &nodes[i]
+		nodes = node.child
+	}
+	return node
+}
+
+// Render template using generated function.
+func (t *Tpl) GenRender(w io.Writer, ctx *Ctx, fn GenFn) (err error) {
+	if t.gen != nil {
+		if t.gen.once.Do(t.genParse); t.gen.err != nil {
+			return t.gen.err
+		}
+	}
+	extO := ctx.extO
+	ctx.extO = len(ctx.ext)
+	err = fn(w, ctx)
+	ctx.extO = extO
+	if err == ErrInterrupt {
+		// Interrupt logic.
+		err = nil
+	}
+	return
+}
+
+// Render template dynamically.
+//
+// Generated code calls it if the context contains variables of types other than generated code expects.
+func (t *Tpl) GenFallback(w io.Writer, ctx *Ctx) error {
+	return t.exec(w, ctx)
+}
+
+// Get value of the context variable for generated code.
+//
+// Returns nil if variable doesn't exist or is a byte slice, counter or alias.
+func (c *Ctx) GenVar(key string) interface{} {
+	for i := 0; i < c.ln; i++ {
+		if v := &c.vars[i]; v.key == key {
+			if len(v.pfx) > 0 {
+				return nil
+			}
+			return v.val
+		}
+	}
+	return nil
+}
+
+// Render the node.
+func (n *GenNode) Render(w io.Writer, ctx *Ctx) error {
+	return n.tpl.renderNode(w, n.node, ctx)
+}
+
+// Evaluate condition of condition node or else-if branch.
+func (n *GenNode) Cond(ctx *Ctx) (bool, error) {
+	return n.tpl.evalCond(n.node, ctx)
+}
+
+// Evaluate case of the switch node sw.
+func (n *GenNode) Case(ctx *Ctx, sw *GenNode) (bool, error) {
+	return n.tpl.evalCase(sw.node, n.node, ctx)
+}
+
+// Execute loop node, body of the loop renders by generated function.
+func (n *GenNode) Loop(w io.Writer, ctx *Ctx, body GenFn) error {
+	switch n.node.typ {
+	case TypeLoopCount:
+		ctx.cloop(n.node, n.tpl, w, loopBody{fn: body})
+	case TypeLoopRange:
+		ctx.rloop(n.node.loopSrcPath, n.node, n.tpl, w, loopBody{fn: body})
+	}
+	return ctx.Err
+}
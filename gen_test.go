@@ -0,0 +1,74 @@
+package dyntpl
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/koykov/inspector/testobj"
+)
+
+var (
+	// Flag to update generated code in testgen package.
+	genUpdate = flag.Bool("gen", false, "update generated code in testgen package")
+
+	genStages = []struct {
+		name, file string
+		// Generated code contains no dynamic evaluation.
+		typed bool
+	}{
+		{"Print", "print", false},
+		{"Cond", "cond", false},
+		{"Switch", "switch", false},
+		{"Loop", "loop", true},
+		{"Fallback", "fallback", false},
+		{"Mode", "mode", false},
+	}
+	genVars = []GenVar{{Name: "user", Val: (*testobj.TestObject)(nil)}}
+)
+
+func TestGenerate(t *testing.T) {
+	for _, stage := range genStages {
+		tree, err := ParseFile("testgen/testdata/"+stage.file+".tpl", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err = Generate(&buf, tree, "testgen", stage.name, genVars...); err != nil {
+			t.Fatal(err)
+		}
+		if stage.typed && strings.Contains(buf.String(), ".GenNode(") {
+			t.Errorf("generated code of %s contains dynamic nodes", stage.file)
+		}
+		path := "testgen/" + stage.file + ".go"
+		if *genUpdate {
+			if err = ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		expect, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), expect) {
+			t.Errorf("generated code of %s mismatch, run tests with -gen flag to update\ngot: %s", stage.file, buf.String())
+		}
+	}
+}
+
+func TestGenerateExtends(t *testing.T) {
+	tree, _ := Parse(tplExtChild, false)
+	if err := Generate(&bytes.Buffer{}, tree, "testgen", "ExtChild"); err != ErrGenExtends {
+		t.Errorf("generate extends error mismatch: %v", err)
+	}
+}
+
+func TestGenerateVar(t *testing.T) {
+	tree, _ := Parse([]byte(`{%= user.Id %}`), false)
+	if err := Generate(&bytes.Buffer{}, tree, "testgen", "Var", GenVar{Name: "user"}); err != ErrGenVar {
+		t.Errorf("generate variable error mismatch: %v", err)
+	}
+}
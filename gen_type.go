@@ -0,0 +1,463 @@
+package dyntpl
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Typed variable of generated code.
+type genVar struct {
+	// Go expression of the variable and its type. Nil type means that variable is bound by the loop, but its type is
+	// unknown, so it hides the variable of outer scope.
+	expr string
+	typ  reflect.Type
+	// Pointer can't be nil, so nil check isn't required.
+	nonNil bool
+	// Variable is the key of native range loop. Keys may be printed and used as indexes only, since comparison of keys
+	// depends on inspector.
+	key bool
+	// Variable is referenced by generated code and must be declared.
+	used bool
+}
+
+// Scope of typed variables, maps names of variables in the template to Go variables.
+type genScope map[string]*genVar
+
+// Typed reference to the data made from variable path.
+type genRef struct {
+	// Go expression of the value and its type.
+	expr string
+	typ  reflect.Type
+	// Conditions that must be true to evaluate the expression (nil checks and bounds of slices).
+	guard []string
+	// Variables used by the expression.
+	vars []*genVar
+	key  bool
+}
+
+var (
+	bytesType = reflect.TypeOf([]byte(nil))
+	int64Type = reflect.TypeOf(int64(0))
+	intType   = reflect.TypeOf(0)
+)
+
+// Copy the scope and bind the variable in the copy.
+func (s genScope) with(name []byte, v *genVar) genScope {
+	c := make(genScope, len(s)+1)
+	for k, x := range s {
+		c[k] = x
+	}
+	c[string(name)] = v
+	return c
+}
+
+// Get condition of the guard.
+func (r *genRef) cond() string {
+	return strings.Join(r.guard, " && ")
+}
+
+// Wrap the expression with the guard.
+func (r *genRef) guarded(expr string) string {
+	if len(r.guard) == 0 {
+		return expr
+	}
+	return "(" + r.cond() + " && " + expr + ")"
+}
+
+// Resolve the variable path to typed reference using the scope.
+//
+// Supports fields of structs (except of promoted fields) and static or typed integer indexes of slices and arrays.
+// Pointers are dereferenced with nil checks.
+func (g *generator) resolve(path *vpath, sc genScope) (r genRef, ok bool) {
+	if path == nil || len(path.seg) == 0 || path.seg[0].idx != nil {
+		return
+	}
+	v := sc[path.seg[0].key]
+	if v == nil || v.typ == nil || (v.key && len(path.seg) > 1) {
+		return
+	}
+	r = genRef{expr: v.expr, typ: v.typ, vars: []*genVar{v}, key: v.key}
+	nonNil := v.nonNil
+	for _, seg := range path.seg[1:] {
+		r.deref(nonNil)
+		nonNil = false
+		switch r.typ.Kind() {
+		case reflect.Struct:
+			if seg.idx != nil {
+				return r, false
+			}
+			f, ok := r.typ.FieldByName(seg.key)
+			if !ok || len(f.PkgPath) > 0 || len(f.Index) != 1 {
+				return r, false
+			}
+			r.expr, r.typ = r.expr+"."+f.Name, f.Type
+		case reflect.Slice, reflect.Array:
+			if !g.index(&r, seg, sc) {
+				return r, false
+			}
+		default:
+			return r, false
+		}
+	}
+	r.deref(nonNil)
+	return r, true
+}
+
+// Dereference pointers of the reference.
+//
+// Selectors of fields and indexes of arrays dereference pointer implicitly, other values wraps with explicit
+// dereference (slices and pointers in parentheses, since index or selector follows).
+func (r *genRef) deref(nonNil bool) {
+	for r.typ.Kind() == reflect.Ptr {
+		if !nonNil {
+			r.guard = append(r.guard, r.expr+" != nil")
+		}
+		nonNil = false
+		r.typ = r.typ.Elem()
+		switch r.typ.Kind() {
+		case reflect.Struct, reflect.Array:
+		case reflect.Slice, reflect.Ptr:
+			r.expr = "(*" + r.expr + ")"
+		default:
+			r.expr = "*" + r.expr
+		}
+	}
+}
+
+// Add index of slice or array to the reference.
+func (g *generator) index(r *genRef, seg vseg, sc genScope) bool {
+	var idx string
+	if seg.idx == nil {
+		i, err := strconv.Atoi(seg.key)
+		if err != nil || i < 0 || (r.typ.Kind() == reflect.Array && i >= r.typ.Len()) {
+			return false
+		}
+		idx = strconv.Itoa(i)
+		if r.typ.Kind() == reflect.Slice {
+			r.guard = append(r.guard, "len("+r.expr+") > "+idx)
+		}
+	} else {
+		ir, ok := g.resolve(seg.idx, sc)
+		if !ok || !isBuiltin(ir.typ) || (!isInt(ir.typ.Kind()) && !isUint(ir.typ.Kind())) {
+			return false
+		}
+		// Index of any integer type is allowed, but bounds must be checked in the wide type.
+		idx = ir.expr
+		r.guard = append(r.guard, ir.guard...)
+		r.vars = append(r.vars, ir.vars...)
+		if isInt(ir.typ.Kind()) {
+			i := conv(ir, reflect.Int64)
+			r.guard = append(r.guard, i+" >= 0", i+" < int64(len("+r.expr+"))")
+		} else {
+			r.guard = append(r.guard, conv(ir, reflect.Uint64)+" < uint64(len("+r.expr+"))")
+		}
+	}
+	r.expr, r.typ = r.expr+"["+idx+"]", r.typ.Elem()
+	return true
+}
+
+// Make typed comparison of the reference with static value.
+//
+// Static value converts to Go constant at generation time like generated inspectors do at render time. Values that
+// can't be converted or overflows the type aren't supported, since inspectors return errors in that case.
+func (g *generator) compare(r genRef, op Op, right []byte) (string, bool) {
+	if r.key || !isBuiltin(r.typ) {
+		return "", false
+	}
+	var (
+		k    = r.typ.Kind()
+		s    = string(right)
+		expr = r.expr
+		lit  string
+	)
+	gop := op.String()
+	if len(gop) > 2 {
+		// Unknown operation.
+		return "", false
+	}
+	switch {
+	case r.typ == bytesType:
+		if op != OpEq && op != OpNq {
+			return "", false
+		}
+		expr, lit = "string("+expr+")", strconv.Quote(s)
+	case k == reflect.String:
+		lit = strconv.Quote(s)
+	case k == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil || (op != OpEq && op != OpNq) {
+			return "", false
+		}
+		if b != (op == OpEq) {
+			expr = "!" + expr
+		}
+		return r.guarded(expr), true
+	case isInt(k):
+		i, err := strconv.ParseInt(s, 0, 0)
+		if err != nil || reflect.Zero(r.typ).OverflowInt(i) {
+			return "", false
+		}
+		lit = strconv.FormatInt(i, 10)
+	case isUint(k):
+		u, err := strconv.ParseUint(s, 0, 0)
+		if err != nil || reflect.Zero(r.typ).OverflowUint(u) {
+			return "", false
+		}
+		lit = strconv.FormatUint(u, 10)
+	case k == reflect.Float32 || k == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) || reflect.Zero(r.typ).OverflowFloat(f) {
+			return "", false
+		}
+		lit = strconv.FormatFloat(f, 'g', -1, 64)
+	default:
+		return "", false
+	}
+	return r.guarded(expr + " " + gop + " " + lit), true
+}
+
+// Make typed comparison of variable with static value, one side of the comparison must be static.
+func (g *generator) cmp(l, r []byte, lp, rp *vpath, sl, sr bool, op Op, sc genScope) (string, []*genVar, bool) {
+	if sl == sr {
+		// Comparison of two static values is senseless, comparison of two variables isn't supported.
+		return "", nil, false
+	}
+	path, right := lp, r
+	if sl {
+		path, right, op = rp, l, op.Swap()
+	}
+	ref, ok := g.resolve(path, sc)
+	if !ok {
+		return "", nil, false
+	}
+	expr, ok := g.compare(ref, op, right)
+	return expr, ref.vars, ok
+}
+
+// Make typed condition of the condition node or else-if branch.
+func (g *generator) cond(node *Node, sc genScope) (string, []*genVar, bool) {
+	if node.condExpr != nil {
+		return g.expr(node.condExpr, sc)
+	}
+	if len(node.condHlp) > 0 {
+		return "", nil, false
+	}
+	return g.cmp(node.condL, node.condR, node.condLPath, node.condRPath, node.condStaticL, node.condStaticR, node.condOp,
+		sc)
+}
+
+// Make typed condition of case ch of the switch node sw.
+func (g *generator) caseCond(sw, ch *Node, sc genScope) (string, []*genVar, bool) {
+	if ch.caseExpr != nil {
+		return g.expr(ch.caseExpr, sc)
+	}
+	if len(sw.switchArg) > 0 {
+		if !ch.caseStaticL {
+			return "", nil, false
+		}
+		ref, ok := g.resolve(sw.switchArgPath, sc)
+		if !ok {
+			return "", nil, false
+		}
+		expr, ok := g.compare(ref, OpEq, ch.caseL)
+		return expr, ref.vars, ok
+	}
+	if len(ch.caseHlp) > 0 {
+		return "", nil, false
+	}
+	return g.cmp(ch.caseL, ch.caseR, ch.caseLPath, ch.caseRPath, ch.caseStaticL, ch.caseStaticR, ch.caseOp, sc)
+}
+
+// Make typed condition of the expression tree. Expressions with condition helpers aren't supported.
+func (g *generator) expr(e *condExpr, sc genScope) (string, []*genVar, bool) {
+	switch e.typ {
+	case exprAnd, exprOr:
+		l, lv, ok := g.expr(e.left, sc)
+		if !ok {
+			return "", nil, false
+		}
+		r, rv, ok := g.expr(e.right, sc)
+		if !ok {
+			return "", nil, false
+		}
+		if e.typ == exprAnd {
+			if e.left.typ == exprOr {
+				l = "(" + l + ")"
+			}
+			if e.right.typ == exprOr {
+				r = "(" + r + ")"
+			}
+			return l + " && " + r, append(lv, rv...), true
+		}
+		return l + " || " + r, append(lv, rv...), true
+	case exprNot:
+		x, vars, ok := g.expr(e.left, sc)
+		return "!(" + x + ")", vars, ok
+	case exprVar:
+		if e.sl {
+			return "", nil, false
+		}
+		ref, ok := g.resolve(e.lp, sc)
+		if !ok || ref.typ.Kind() != reflect.Bool {
+			return "", nil, false
+		}
+		expr, ok := g.compare(ref, OpEq, exprTrue)
+		return expr, ref.vars, ok
+	case exprCmp:
+		return g.cmp(e.l, e.r, e.lp, e.rp, e.sl, e.sr, e.op, sc)
+	}
+	return "", nil, false
+}
+
+// Check if the type of the reference may be printed directly.
+func (g *generator) printable(r genRef) bool {
+	if !isBuiltin(r.typ) {
+		return false
+	}
+	switch k := r.typ.Kind(); {
+	case r.typ == bytesType, k == reflect.String, k == reflect.Bool, isInt(k), isUint(k),
+		k == reflect.Float32, k == reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Check if type is a predeclared Go type or bytes slice. Values of named types convert to bytes by own rules.
+func isBuiltin(typ reflect.Type) bool {
+	return typ == bytesType || (len(typ.PkgPath()) == 0 && len(typ.Name()) > 0)
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUint(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uint64
+}
+
+// Check if name of the variable may be used as Go identifier.
+func isIdent(name []byte) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i, c := range name {
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Walk over nodes and collect names of variables: free references, names assigned by context nodes and names bound by
+// loops.
+//
+// Names bound by the loop are not free inside of the loop body. Names bound by nested loops are collected to rebound
+// list, since variable of the outer loop changes after the nested loop.
+func (g *generator) scan(nodes []Node, bound map[string]struct{}) {
+	for i := 0; i < len(nodes); i++ {
+		node := &nodes[i]
+		node.refs(func(name string) {
+			if _, ok := bound[name]; !ok {
+				g.free[name] = struct{}{}
+			}
+		})
+		var names [][]byte
+		switch node.typ {
+		case TypeCtx:
+			g.asgn[string(node.ctxVar)] = struct{}{}
+		case TypeCounter:
+			g.asgn[string(node.cntrVar)] = struct{}{}
+		case TypeLoopRange:
+			names = append(names, node.loopKey, node.loopVal)
+		case TypeLoopCount:
+			names = append(names, node.loopCnt)
+		case TypeMacro:
+			for _, param := range node.macroParam {
+				names = append(names, param.name)
+			}
+		}
+		if len(node.child) == 0 {
+			continue
+		}
+		cbound := bound
+		if len(names) > 0 {
+			cbound = make(map[string]struct{}, len(bound)+len(names))
+			for k := range bound {
+				cbound[k] = struct{}{}
+			}
+			for _, name := range names {
+				if len(name) == 0 {
+					continue
+				}
+				if _, ok := cbound[string(name)]; ok {
+					g.rebound[string(name)] = struct{}{}
+				}
+				cbound[string(name)] = struct{}{}
+				if node.typ != TypeMacro {
+					g.bound[string(name)] = struct{}{}
+				}
+			}
+		}
+		g.scan(node.child, cbound)
+	}
+}
+
+// Call fn for root names of all variables referenced by the node (except of child nodes).
+func (n *Node) refs(fn func(string)) {
+	paths := [...]*vpath{n.rawPath, n.ctxSrcPath, n.cntrVarPath, n.condLPath, n.condRPath, n.loopSrcPath, n.loopCntPath,
+		n.loopLimPath, n.switchArgPath, n.caseLPath, n.caseRPath}
+	for _, p := range paths {
+		p.refs(fn)
+	}
+	argRefs(n.condHlpArg, fn)
+	argRefs(n.caseHlpArg, fn)
+	argRefs(n.macroArg, fn)
+	n.condExpr.refs(fn)
+	n.caseExpr.refs(fn)
+	for _, a := range n.incArg {
+		argRefs([]*arg{a.val}, fn)
+	}
+	for _, a := range n.macroParam {
+		argRefs([]*arg{a.val}, fn)
+	}
+	for i := range n.mod {
+		argRefs(n.mod[i].arg, fn)
+	}
+}
+
+// Call fn for root names of the path and its index expressions.
+func (p *vpath) refs(fn func(string)) {
+	if p == nil {
+		return
+	}
+	for i, seg := range p.seg {
+		if seg.idx != nil {
+			seg.idx.refs(fn)
+		} else if i == 0 {
+			fn(seg.key)
+		}
+	}
+}
+
+// Call fn for root names of variables of the expression tree.
+func (e *condExpr) refs(fn func(string)) {
+	if e == nil {
+		return
+	}
+	e.lp.refs(fn)
+	e.rp.refs(fn)
+	argRefs(e.hlpArg, fn)
+	e.left.refs(fn)
+	e.right.refs(fn)
+}
+
+// Call fn for root names of variables of the arguments.
+func argRefs(args []*arg, fn func(string)) {
+	for _, a := range args {
+		if a != nil && !a.static {
+			a.path.refs(fn)
+		}
+	}
+}
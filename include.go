@@ -0,0 +1,34 @@
+package dyntpl
+
+import (
+	"io"
+
+	"github.com/koykov/fastconv"
+)
+
+// Render included template with explicit arguments, ex:
+// {% include row with item=v, idx=k only %}
+//
+// Arguments binds as local variables of the sub-template. Outer variables with the same names are hidden, and "only"
+// keyword hides all outer variables, so sub-template sees nothing but its arguments.
+func renderIncScope(w io.Writer, tpl *Tpl, node *Node, ctx *Ctx) (err error) {
+	s := ctx.openScope()
+	for _, a := range node.incArg {
+		ctx.bindVar(s, fastconv.B2S(a.name), a.val)
+	}
+	// Arguments must be bound before hiding since they may use outer variables with the same names.
+	if node.incOnly {
+		ctx.hideAll(s)
+	} else {
+		for _, a := range node.incArg {
+			ctx.hideVar(s, fastconv.B2S(a.name))
+		}
+	}
+	if ctx.Err == nil {
+		err = render(w, tpl, ctx)
+	} else {
+		err = ctx.Err
+	}
+	ctx.closeScope(s)
+	return
+}
@@ -1,30 +1,41 @@
 package dyntpl
 
+import "github.com/koykov/inspector"
+
 func init() {
+	// Register built-in inspectors.
+	inspector.RegisterInspector("map", mapIns)
+	inspector.RegisterInspector("reflect", reflectIns)
+
+	registerBuiltin(defaultSet)
+
+	// Register test modifiers.
+	RegisterModFn("testNameOf", "", modTestNameOf)
+}
+
+// Register builtin modifiers and condition helpers in the set.
+func registerBuiltin(s *Set) {
 	// Register simple builtin modifiers.
-	RegisterModFn("default", "def", modDefault)
-	RegisterModFn("ifThen", "if", modIfThen)
-	RegisterModFn("ifThenElse", "ifel", modIfThenElse)
+	s.RegisterModFn("default", "def", modDefault)
+	s.RegisterModFn("ifThen", "if", modIfThen)
+	s.RegisterModFn("ifThenElse", "ifel", modIfThenElse)
 
 	// Register builtin escape/quote modifiers.
-	RegisterModFn("jsonEscape", "je", modJsonEscape)
-	RegisterModFn("jsonQuote", "jq", modJsonQuote)
-	RegisterModFn("htmlEscape", "he", modHtmlEscape)
-	RegisterModFn("urlEncode", "ue", modUrlEncode)
+	s.RegisterModFn("jsonEscape", "je", modJsonEscape)
+	s.RegisterModFn("jsonQuote", "jq", modJsonQuote)
+	s.RegisterModFn("htmlEscape", "he", modHtmlEscape)
+	s.RegisterModFn("urlEncode", "ue", modUrlEncode)
 
 	// Register builtin round modifiers.
-	RegisterModFn("round", "round", modRound)
-	RegisterModFn("roundPrec", "roundp", modRoundPrec)
-	RegisterModFn("ceil", "ceil", modCeil)
-	RegisterModFn("ceilPrec", "ceilp", modCeilPrec)
-	RegisterModFn("floor", "floor", modFloor)
-	RegisterModFn("floorPrec", "floorp", modFloorPrec)
+	s.RegisterModFn("round", "round", modRound)
+	s.RegisterModFn("roundPrec", "roundp", modRoundPrec)
+	s.RegisterModFn("ceil", "ceil", modCeil)
+	s.RegisterModFn("ceilPrec", "ceilp", modCeilPrec)
+	s.RegisterModFn("floor", "floor", modFloor)
+	s.RegisterModFn("floorPrec", "floorp", modFloorPrec)
 
 	// Register builtin condition helpers.
-	RegisterCondFn("lenEq0", condLenEq0)
-	RegisterCondFn("lenGt0", condLenGt0)
-	RegisterCondFn("lenGtq0", condLenGtq0)
-
-	// Register test modifiers.
-	RegisterModFn("testNameOf", "", modTestNameOf)
+	s.RegisterCondFn("lenEq0", condLenEq0)
+	s.RegisterCondFn("lenGt0", condLenGt0)
+	s.RegisterCondFn("lenGtq0", condLenGtq0)
 }
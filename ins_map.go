@@ -0,0 +1,273 @@
+package dyntpl
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/koykov/inspector"
+)
+
+// MapInspector is a built-in inspector of plain Go maps and decoded JSON documents.
+//
+// Data is a tree of maps (map[string]interface{}, map[string]string), slices ([]interface{}, []string,
+// []map[string]interface{}) and scalar values. Path keys of slices are indexes, keys of maps are sorted during loops to
+// make output stable. See Ctx.SetMap() and Ctx.SetJSON().
+type MapInspector struct {
+	inspector.BaseInspector
+}
+
+// Keys of maps in nested loops. Each loop appends its keys to the end of buffer, sorts and truncates them after
+// iterations, so sorting of keys doesn't allocate memory. Values of string maps pass to the loop as pointers to slots
+// of val buffer to avoid conversions of strings to interfaces.
+type mapKeys struct {
+	buf []string
+	lo  int
+	val []string
+}
+
+var (
+	// Shared instance of the map inspector, it has no state.
+	mapIns = &MapInspector{}
+	// Shared instance of the static inspector to set keys of loops.
+	staticIns = &inspector.StaticInspector{}
+)
+
+func (i *MapInspector) Get(src interface{}, path ...string) (interface{}, error) {
+	var buf interface{}
+	err := i.GetTo(src, &buf, path...)
+	return buf, err
+}
+
+func (i *MapInspector) GetTo(src interface{}, buf *interface{}, path ...string) error {
+	*buf = mapWalk(src, path)
+	return nil
+}
+
+func (i *MapInspector) Set(dst, value interface{}, path ...string) error {
+	if len(path) == 0 {
+		return nil
+	}
+	// Only maps of interfaces may be modified.
+	if m, ok := mapWalk(dst, path[:len(path)-1]).(map[string]interface{}); ok {
+		m[path[len(path)-1]] = value
+	}
+	return nil
+}
+
+func (i *MapInspector) Cmp(src interface{}, cond inspector.Op, right string, result *bool, path ...string) error {
+	*result = mapCmp(mapWalk(src, path), cond, right)
+	return nil
+}
+
+func (i *MapInspector) Loop(src interface{}, l inspector.Looper, buf *[]byte, path ...string) error {
+	switch x := mapWalk(src, path).(type) {
+	case []interface{}:
+		for k := range x {
+			if i.loopIdx(l, buf, k, x[k]) == inspector.LoopCtlBrk {
+				break
+			}
+		}
+	case []string:
+		for k := range x {
+			if i.loopIdx(l, buf, k, &x[k]) == inspector.LoopCtlBrk {
+				break
+			}
+		}
+	case []map[string]interface{}:
+		for k := range x {
+			if i.loopIdx(l, buf, k, x[k]) == inspector.LoopCtlBrk {
+				break
+			}
+		}
+	case map[string]interface{}:
+		keys := mapLoopKeys(l)
+		lo := len(keys.buf)
+		for k := range x {
+			keys.buf = append(keys.buf, k)
+		}
+		keys.lo = lo
+		sort.Sort(keys)
+		for j, hi := lo, len(keys.buf); j < hi; j++ {
+			k := keys.buf[j]
+			if i.loopKey(l, buf, k, x[k]) == inspector.LoopCtlBrk {
+				break
+			}
+		}
+		keys.buf = keys.buf[:lo]
+	case map[string]string:
+		keys := mapLoopKeys(l)
+		lo := len(keys.buf)
+		for k := range x {
+			keys.buf = append(keys.buf, k)
+		}
+		keys.lo = lo
+		sort.Sort(keys)
+		vi := len(keys.val)
+		keys.val = append(keys.val, "")
+		for j, hi := lo, len(keys.buf); j < hi; j++ {
+			k := keys.buf[j]
+			keys.val[vi] = x[k]
+			if i.loopKey(l, buf, k, &keys.val[vi]) == inspector.LoopCtlBrk {
+				break
+			}
+		}
+		keys.buf, keys.val = keys.buf[:lo], keys.val[:vi]
+	}
+	return nil
+}
+
+// Make loop iteration with index key.
+func (i *MapInspector) loopIdx(l inspector.Looper, buf *[]byte, k int, val interface{}) inspector.LoopCtl {
+	if l.RequireKey() {
+		*buf = strconv.AppendInt((*buf)[:0], int64(k), 10)
+		l.SetKey(buf, staticIns)
+	}
+	l.SetVal(val, i)
+	return l.Iterate()
+}
+
+// Make loop iteration with string key.
+func (i *MapInspector) loopKey(l inspector.Looper, buf *[]byte, k string, val interface{}) inspector.LoopCtl {
+	if l.RequireKey() {
+		*buf = append((*buf)[:0], k...)
+		l.SetKey(buf, staticIns)
+	}
+	l.SetVal(val, i)
+	return l.Iterate()
+}
+
+// Get keys buffer of the context of range loop.
+func mapLoopKeys(l inspector.Looper) *mapKeys {
+	if rl, ok := l.(*RangeLoop); ok && rl.ctx != nil {
+		return &rl.ctx.bufMK
+	}
+	// Foreign looper, no context to take buffer.
+	return &mapKeys{}
+}
+
+func (k *mapKeys) Len() int {
+	return len(k.buf) - k.lo
+}
+
+func (k *mapKeys) Less(i, j int) bool {
+	return k.buf[k.lo+i] < k.buf[k.lo+j]
+}
+
+func (k *mapKeys) Swap(i, j int) {
+	k.buf[k.lo+i], k.buf[k.lo+j] = k.buf[k.lo+j], k.buf[k.lo+i]
+}
+
+// Walk over data using path and get the value. Returns nil if path doesn't exist.
+func mapWalk(src interface{}, path []string) interface{} {
+	for _, key := range path {
+		switch x := src.(type) {
+		case map[string]interface{}:
+			src = x[key]
+		case map[string]string:
+			v, ok := x[key]
+			if !ok {
+				return nil
+			}
+			src = v
+		case []interface{}:
+			i, ok := mapIdx(key, len(x))
+			if !ok {
+				return nil
+			}
+			src = x[i]
+		case []string:
+			i, ok := mapIdx(key, len(x))
+			if !ok {
+				return nil
+			}
+			src = x[i]
+		case []map[string]interface{}:
+			i, ok := mapIdx(key, len(x))
+			if !ok {
+				return nil
+			}
+			src = x[i]
+		default:
+			return nil
+		}
+	}
+	return src
+}
+
+// Convert key to index of slice with given length.
+func mapIdx(key string, ln int) (int, bool) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i >= ln {
+		return 0, false
+	}
+	return i, true
+}
+
+// Compare value with right side of the condition.
+func mapCmp(val interface{}, cond inspector.Op, right string) bool {
+	switch x := val.(type) {
+	case nil:
+		return cmpStr("", cond, right)
+	case string:
+		return cmpStr(x, cond, right)
+	case *string:
+		return cmpStr(*x, cond, right)
+	case bool:
+		r, err := strconv.ParseBool(right)
+		if err != nil {
+			return cond == inspector.OpNq
+		}
+		return cmpStr(strconv.FormatBool(x), cond, strconv.FormatBool(r))
+	case float64:
+		return cmpNum(x, cond, right)
+	case int:
+		return cmpNum(float64(x), cond, right)
+	case int64:
+		return cmpNum(float64(x), cond, right)
+	case uint64:
+		return cmpNum(float64(x), cond, right)
+	}
+	return false
+}
+
+// Compare strings.
+func cmpStr(l string, cond inspector.Op, r string) bool {
+	switch cond {
+	case inspector.OpEq:
+		return l == r
+	case inspector.OpNq:
+		return l != r
+	case inspector.OpGt:
+		return l > r
+	case inspector.OpGtq:
+		return l >= r
+	case inspector.OpLt:
+		return l < r
+	case inspector.OpLtq:
+		return l <= r
+	}
+	return false
+}
+
+// Compare numbers.
+func cmpNum(l float64, cond inspector.Op, right string) bool {
+	r, err := strconv.ParseFloat(right, 64)
+	if err != nil {
+		return cond == inspector.OpNq
+	}
+	switch cond {
+	case inspector.OpEq:
+		return l == r
+	case inspector.OpNq:
+		return l != r
+	case inspector.OpGt:
+		return l > r
+	case inspector.OpGtq:
+		return l >= r
+	case inspector.OpLt:
+		return l < r
+	case inspector.OpLtq:
+		return l <= r
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+package dyntpl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/koykov/inspector"
+	"github.com/koykov/x2bytes"
+)
+
+// ReflectInspector is a fallback inspector of arbitrary data based on reflection.
+//
+// It's used when Ctx.Set() receives nil inspector, so new structs may be used in templates without generating
+// inspectors. Supports exported fields of structs (including promoted), keys of maps, indexes of slices and arrays.
+// Lookups of fields are cached per type. Reflection is much slower than generated inspectors, so use it for
+// prototyping and rarely used templates.
+type ReflectInspector struct {
+	inspector.BaseInspector
+}
+
+// Keys of maps in nested loops, works like mapKeys.
+type reflectKeys struct {
+	buf []reflect.Value
+	lo  int
+}
+
+// Key of fields cache.
+type reflectField struct {
+	typ  reflect.Type
+	name string
+}
+
+var (
+	// Shared instance of the reflect inspector, it has no state.
+	reflectIns = &ReflectInspector{}
+	// Cache of fields indexes by type and name.
+	reflectFields sync.Map
+)
+
+func (i *ReflectInspector) Get(src interface{}, path ...string) (interface{}, error) {
+	var buf interface{}
+	err := i.GetTo(src, &buf, path...)
+	return buf, err
+}
+
+func (i *ReflectInspector) GetTo(src interface{}, buf *interface{}, path ...string) error {
+	v, ok := reflectWalk(src, path)
+	if !ok {
+		*buf = nil
+		return nil
+	}
+	*buf = reflectVal(v)
+	return nil
+}
+
+func (i *ReflectInspector) Set(dst, value interface{}, path ...string) error {
+	v, ok := reflectWalk(dst, path)
+	if !ok || !v.CanSet() {
+		return nil
+	}
+	val := reflect.ValueOf(value)
+	if val.IsValid() && val.Type().AssignableTo(v.Type()) {
+		v.Set(val)
+	}
+	return nil
+}
+
+func (i *ReflectInspector) Cmp(src interface{}, cond inspector.Op, right string, result *bool, path ...string) error {
+	v, ok := reflectWalk(src, path)
+	if !ok {
+		*result = cmpStr("", cond, right)
+		return nil
+	}
+	v = reflectIndirect(v)
+	switch v.Kind() {
+	case reflect.String:
+		*result = cmpStr(v.String(), cond, right)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		*result = cmpNum(float64(v.Int()), cond, right)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		*result = cmpNum(float64(v.Uint()), cond, right)
+	case reflect.Float32, reflect.Float64:
+		*result = cmpNum(v.Float(), cond, right)
+	case reflect.Bool:
+		*result = mapCmp(v.Bool(), cond, right)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			*result = cmpStr(string(v.Bytes()), cond, right)
+		}
+	case reflect.Invalid:
+		*result = cmpStr("", cond, right)
+	}
+	return nil
+}
+
+func (i *ReflectInspector) Loop(src interface{}, l inspector.Looper, buf *[]byte, path ...string) error {
+	v, ok := reflectWalk(src, path)
+	if !ok {
+		return nil
+	}
+	v = reflectIndirect(v)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for k := 0; k < v.Len(); k++ {
+			if l.RequireKey() {
+				*buf = strconv.AppendInt((*buf)[:0], int64(k), 10)
+				l.SetKey(buf, staticIns)
+			}
+			l.SetVal(reflectVal(v.Index(k)), i)
+			if l.Iterate() == inspector.LoopCtlBrk {
+				break
+			}
+		}
+	case reflect.Map:
+		keys := reflectLoopKeys(l)
+		lo := len(keys.buf)
+		for it := v.MapRange(); it.Next(); {
+			keys.buf = append(keys.buf, it.Key())
+		}
+		keys.lo = lo
+		sort.Sort(keys)
+		for j, hi := lo, len(keys.buf); j < hi; j++ {
+			k := keys.buf[j]
+			if l.RequireKey() {
+				var err error
+				if *buf, err = x2bytes.ToBytesWR((*buf)[:0], k.Interface()); err != nil {
+					*buf = append((*buf)[:0], fmt.Sprint(k.Interface())...)
+				}
+				l.SetKey(buf, staticIns)
+			}
+			l.SetVal(reflectVal(v.MapIndex(k)), i)
+			if l.Iterate() == inspector.LoopCtlBrk {
+				break
+			}
+		}
+		keys.buf = keys.buf[:lo]
+	}
+	return nil
+}
+
+// Get keys buffer of the context of range loop.
+func reflectLoopKeys(l inspector.Looper) *reflectKeys {
+	if rl, ok := l.(*RangeLoop); ok && rl.ctx != nil {
+		return &rl.ctx.bufRK
+	}
+	// Foreign looper, no context to take buffer.
+	return &reflectKeys{}
+}
+
+func (k *reflectKeys) Len() int {
+	return len(k.buf) - k.lo
+}
+
+func (k *reflectKeys) Less(i, j int) bool {
+	return reflectLess(k.buf[k.lo+i], k.buf[k.lo+j])
+}
+
+func (k *reflectKeys) Swap(i, j int) {
+	k.buf[k.lo+i], k.buf[k.lo+j] = k.buf[k.lo+j], k.buf[k.lo+i]
+}
+
+// Walk over data using path and get the value.
+func reflectWalk(src interface{}, path []string) (reflect.Value, bool) {
+	v := reflect.ValueOf(src)
+	for _, key := range path {
+		v = reflectIndirect(v)
+		switch v.Kind() {
+		case reflect.Struct:
+			idx := reflectFieldIdx(v.Type(), key)
+			if idx == nil {
+				return v, false
+			}
+			for j, fi := range idx {
+				if j > 0 && v.Kind() == reflect.Ptr {
+					if v.IsNil() {
+						// Nil pointer to embedded struct.
+						return v, false
+					}
+					v = v.Elem()
+				}
+				v = v.Field(fi)
+			}
+		case reflect.Map:
+			k, ok := reflectKey(v.Type().Key(), key)
+			if !ok {
+				return v, false
+			}
+			if v = v.MapIndex(k); !v.IsValid() {
+				return v, false
+			}
+		case reflect.Slice, reflect.Array:
+			n, err := strconv.Atoi(key)
+			if err != nil || n < 0 || n >= v.Len() {
+				return v, false
+			}
+			v = v.Index(n)
+		default:
+			return v, false
+		}
+	}
+	return v, v.IsValid()
+}
+
+// Get index of exported field of the struct type using cache.
+func reflectFieldIdx(typ reflect.Type, name string) []int {
+	key := reflectField{typ: typ, name: name}
+	if idx, ok := reflectFields.Load(key); ok {
+		return idx.([]int)
+	}
+	var idx []int
+	if sf, ok := typ.FieldByName(name); ok && sf.PkgPath == "" {
+		idx = sf.Index
+	}
+	reflectFields.Store(key, idx)
+	return idx
+}
+
+// Convert path key to the key of the map.
+func reflectKey(typ reflect.Type, key string) (reflect.Value, bool) {
+	var v reflect.Value
+	switch typ.Kind() {
+	case reflect.String:
+		v = reflect.ValueOf(key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return v, false
+		}
+		v = reflect.ValueOf(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return v, false
+		}
+		v = reflect.ValueOf(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(key, 64)
+		if err != nil {
+			return v, false
+		}
+		v = reflect.ValueOf(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(key)
+		if err != nil {
+			return v, false
+		}
+		v = reflect.ValueOf(b)
+	default:
+		return v, false
+	}
+	if v.Type() != typ && !v.Type().ConvertibleTo(typ) {
+		return v, false
+	}
+	return v.Convert(typ), true
+}
+
+// Dereference pointers and interfaces.
+func reflectIndirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// Get value of the reflection object.
+//
+// Pointer to the value is preferred to avoid copying, like generated inspectors do.
+func reflectVal(v reflect.Value) interface{} {
+	if v = reflectIndirect(v); v.CanAddr() {
+		return v.Addr().Interface()
+	}
+	return v.Interface()
+}
+
+// Compare keys of the map to iterate them in stable order.
+func reflectLess(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	}
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
@@ -0,0 +1,129 @@
+package dyntpl
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// LoadError describes the problem with template file occurred during loading.
+type LoadError struct {
+	// Path of the file relative to the root of loaded file system.
+	File string
+	// Error of reading or parsing, *ParseError in most cases.
+	Err error
+}
+
+// LoadErrors is a list of per-file errors returned by LoadFS() and LoadDir().
+type LoadErrors []*LoadError
+
+func (e *LoadError) Error() string {
+	return e.File + ": " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+func (e LoadErrors) Error() string {
+	var buf bytes.Buffer
+	for i, err := range e {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
+
+// LoadDir parses and registers all templates in the directory and its subdirectories.
+//
+// See LoadFS().
+func LoadDir(dir, pattern string, keepFmt bool) error {
+	return defaultSet.LoadDir(dir, pattern, keepFmt)
+}
+
+// LoadFS parses and registers all templates in the file system, e.g. embed.FS.
+//
+// Files are matched against the pattern (see path.Match), pattern without slashes matches base name of the file, e.g.
+// "*.tpl", otherwise it matches full relative path. ID of the template is a relative path without extension, so file
+// "sidebar/right.tpl" registers as "sidebar/right" and may be included as {% include sidebar/right %}. Use fs.Sub()
+// to load templates from subdirectory of embed.FS.
+//
+// Loading doesn't stop on errors: valid templates are registered and problems are reported as LoadErrors.
+func LoadFS(fsys fs.FS, pattern string, keepFmt bool) error {
+	return defaultSet.LoadFS(fsys, pattern, keepFmt)
+}
+
+// LoadDir parses and registers all templates of the directory in the set.
+//
+// See LoadDir().
+func (s *Set) LoadDir(dir, pattern string, keepFmt bool) error {
+	return s.LoadFS(os.DirFS(dir), pattern, keepFmt)
+}
+
+// LoadFS parses and registers all templates of the file system in the set.
+//
+// See LoadFS().
+func (s *Set) LoadFS(fsys fs.FS, pattern string, keepFmt bool) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	var (
+		errs  LoadErrors
+		trees = make(map[string]*Tree)
+	)
+	err := fs.WalkDir(fsys, ".", func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if file == "." {
+				return err
+			}
+			errs = append(errs, &LoadError{File: file, Err: err})
+			return nil
+		}
+		if d.IsDir() || !loadMatch(pattern, file) {
+			return nil
+		}
+		id, tree, err := s.loadFile(fsys, file, keepFmt)
+		if err != nil {
+			errs = append(errs, &LoadError{File: file, Err: err})
+			return nil
+		}
+		trees[id] = tree
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Register all templates at once.
+	s.RegisterTplBatch(trees)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Check if file matches the pattern.
+func loadMatch(pattern, file string) bool {
+	name := file
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(file)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// Parse the file, ID of the template is relative path without extension.
+func (s *Set) loadFile(fsys fs.FS, file string, keepFmt bool) (string, *Tree, error) {
+	raw, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return "", nil, err
+	}
+	tree, err := s.Parse(raw, keepFmt)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSuffix(file, path.Ext(file)), tree, nil
+}
@@ -0,0 +1,51 @@
+package dyntpl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.tpl":             {Data: []byte(`<main>{%= user.Name %}</main>{% include sidebar/right %}`)},
+		"sidebar/right.tpl":    {Data: []byte(`<aside>{% include sidebar/inc/item %}</aside>`)},
+		"sidebar/inc/item.tpl": {Data: []byte(`{%= user.Status %}`)},
+		"sidebar/broken.tpl":   {Data: []byte(`{% if a && (b %}x{% endif %}`)},
+		"readme.md":            {Data: []byte(`{% if %}`)},
+	}
+	err := LoadFS(fsys, "*.tpl", false)
+	var errs LoadErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].File != "sidebar/broken.tpl" ||
+		!errors.Is(errs[0], ErrBadCond) {
+		t.Errorf("load errors mismatch: %v", err)
+	}
+
+	ctx := NewCtx()
+	ctx.Set("user", user, &ins)
+	result, err := Render("page", ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	expect := []byte(`<main>John</main><aside>78</aside>`)
+	if !bytes.Equal(result, expect) {
+		t.Errorf("loaded tpl mismatch\nexp: %s\ngot: %s", expect, result)
+	}
+
+	if err = LoadFS(fsys, "[", false); err == nil {
+		t.Error("bad pattern error expected")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	if err := LoadDir("testgen/testdata", "*.tpl", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"cond", "loop", "switch"} {
+		tpl := defaultSet.getTpl(id)
+		if tpl == nil {
+			t.Errorf("template %s isn't loaded", id)
+		}
+	}
+}
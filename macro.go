@@ -0,0 +1,41 @@
+package dyntpl
+
+import (
+	"io"
+
+	"github.com/koykov/fastconv"
+)
+
+// Render macro call.
+//
+// Arguments of the call binds as local variables, visible only inside the macro body.
+func (t *Tpl) renderMacro(w io.Writer, node *Node, ctx *Ctx) (err error) {
+	macro, ok := t.tree.macros[fastconv.B2S(node.macro)]
+	if !ok {
+		return ErrMacroNotFound
+	}
+	s := ctx.openScope()
+	for i, param := range macro.macroParam {
+		var a *arg
+		if i < len(node.macroArg) {
+			a = node.macroArg[i]
+		} else if param.val != nil {
+			a = param.val
+		} else {
+			ctx.closeScope(s)
+			return ErrMacroNoArg
+		}
+		ctx.bindVar(s, fastconv.B2S(param.name), a)
+	}
+	// Arguments must be bound before hiding since they may use outer variables with the same names.
+	for _, param := range macro.macroParam {
+		ctx.hideVar(s, fastconv.B2S(param.name))
+	}
+	if ctx.Err == nil {
+		err = t.renderNodes(w, macro.child, ctx)
+	} else {
+		err = ctx.Err
+	}
+	ctx.closeScope(s)
+	return
+}
@@ -22,22 +22,32 @@ type mod struct {
 	arg []*arg
 }
 
-var (
-	// Registry of modifiers.
-	modRegistry = map[string]ModFn{}
-)
-
-// Register new modifier function.
+// Register new modifier function in the default set.
 func RegisterModFn(name, alias string, mod ModFn) {
-	modRegistry[name] = mod
+	defaultSet.RegisterModFn(name, alias, mod)
+}
+
+// Get modifier from the default set.
+func GetModFn(name string) *ModFn {
+	return defaultSet.GetModFn(name)
+}
+
+// Register new modifier function in the set.
+func (s *Set) RegisterModFn(name, alias string, mod ModFn) {
+	s.rmux.Lock()
+	s.mod[name] = mod
 	if len(alias) > 0 {
-		modRegistry[alias] = mod
+		s.mod[alias] = mod
 	}
+	s.rmux.Unlock()
 }
 
-// Get modifier from the registry.
-func GetModFn(name string) *ModFn {
-	if fn, ok := modRegistry[name]; ok {
+// Get modifier from the set.
+func (s *Set) GetModFn(name string) *ModFn {
+	s.rmux.RLock()
+	fn, ok := s.mod[name]
+	s.rmux.RUnlock()
+	if ok {
 		return &fn
 	}
 	return nil
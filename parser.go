@@ -7,30 +7,65 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/koykov/bytealg"
 	"github.com/koykov/fastconv"
 )
 
+const (
+	// Parse modes.
+	modeCollect = 1 << iota
+	modeStrict
+)
+
 const (
 	// Types of targets.
 	targetCond = iota
 	targetLoop
 	targetSwitch
+	targetBlock
+	targetMacro
 )
 
 // Parser object.
 type Parser struct {
+	// Set to resolve modifiers and condition helpers.
+	set *Set
 	// Keep format flag. Remove all new lines and tabulations when false.
 	keepFmt bool
 	// Template body to parse.
 	tpl []byte
+	// Original template source and spans removed from it, see cut().
+	src  []byte
+	cuts [][]cutSpan
+
+	// Collect and strict mode flags, errors found and stack of opened control structures, see ParseAll() and
+	// ParseStrict().
+	collect bool
+	strict  bool
+	errs    ParseErrors
+	stack   []ctlFrame
+	// Bounds of the current control structure.
+	pos, end int
+
+	// Counters (depths) of conditions, loops, switches, blocks and macros.
+	cc, cl, cs, cb, cm int
+	// Stack of flags of opened switches, flag is set for switch with argument.
+	swArg []bool
+	// Stack of opened conditions.
+	conds []condFrame
+}
 
-	// Counters (depths) of conditions, loops and switches.
-	cc, cl, cs int
+// Opened condition, need to check else-if branches.
+type condFrame struct {
+	// Depth of all control structures at the condition.
+	depth int
+	// Else branch caught.
+	els bool
 }
 
-// Target is a storage of depths needed to provide proper out from conditions, loops and switches control structures.
+// Target is a storage of depths needed to provide proper out from conditions, loops, switches, blocks and macros control structures.
 type target map[int]int
 
 var (
@@ -54,6 +89,7 @@ var (
 	loopEnd    = []byte("endfor")
 	loopBrk    = []byte("break")
 	loopCnt    = []byte("continue")
+	swCase     = []byte("case ")
 	swDefault  = []byte("default")
 	swEnd      = []byte("endswitch")
 	jq         = []byte("jsonquote")
@@ -62,6 +98,8 @@ var (
 	heEnd      = []byte("endhtmlescape")
 	ue         = []byte("urlencode")
 	ueEnd      = []byte("endurlencode")
+	blockSuper = []byte("super")
+	macroEnd   = []byte("endmacro")
 
 	// Print prefixes and replacements.
 	outmJ = []byte("j")          // json quote
@@ -113,10 +151,10 @@ var (
 	reCntrOp1  = regexp.MustCompile(`(?:counter|cntr) (\w+)(\+\d+|-\d+)`)
 
 	// Regexp to parse condition instruction.
-	reCond        = regexp.MustCompile(`if .*`)
-	reCondExpr    = regexp.MustCompile(`if (.*)(==|!=|>=|<=|>|<)(.*)`)
-	reCondHelper  = regexp.MustCompile(`if ([^(]+)\(*([^)]*)\)`)
-	reCondComplex = regexp.MustCompile(`if .*&&|\|\||\(|\).*`)
+	reCond        = regexp.MustCompile(`^if (.*)`)
+	reCondElif    = regexp.MustCompile(`^(?:else\s*if|elif) (.*)`)
+	reCondExpr    = regexp.MustCompile(`(.*)(==|!=|>=|<=|>|<)(.*)`)
+	reCondComplex = regexp.MustCompile(`&&|\|\||\(|\)|![^=]`)
 
 	// Regexp to parse loop instruction.
 	reLoop      = regexp.MustCompile(`for .*`)
@@ -124,35 +162,79 @@ var (
 	reLoopCount = regexp.MustCompile(`for (\w*)\s*:*=\s*(\w+)\s*;\s*\w+\s*(<|<=|>|>=|!=)+\s*([^;]+)\s*;\s*\w*(--|\+\+)+\s*(?:separator|sep)*\s*(.*)`)
 
 	// Regexp to parse switch instruction.
-	reSwitch           = regexp.MustCompile(`^switch\s*(.*)`)
-	reSwitchCase       = regexp.MustCompile(`case ([^<=>!]+)([<=>!]{2})*(.*)`)
-	reSwitchCaseHelper = regexp.MustCompile(`case ([^(]+)\(*([^)]*)\)`)
+	reSwitch            = regexp.MustCompile(`^switch\s*(.*)`)
+	reSwitchCase        = regexp.MustCompile(`case ([^<=>!]+)([<=>!]{2})*(.*)`)
+	reSwitchCaseHelper  = regexp.MustCompile(`case ([^(]+)\(*([^)]*)\)`)
+	reSwitchCaseComplex = regexp.MustCompile(`^case\s+(?:.*(?:&&|\|\||![^=])|\()`)
 
 	// Regexp to parse include instruction.
-	reInc = regexp.MustCompile(`include (.*)`)
+	reInc     = regexp.MustCompile(`include (.*)`)
+	reIncWith = regexp.MustCompile(`^(.*?)(?:\s+with\s+(.*?))?(\s+only)?$`)
+
+	// Regexp to parse inheritance instructions.
+	reExtends  = regexp.MustCompile(`^extends\s+(.*)`)
+	reBlock    = regexp.MustCompile(`^block\s+([^\s]+)`)
+	reBlockEnd = regexp.MustCompile(`^endblock\s*[^\s]*$`)
+
+	// Regexp to parse macro instructions.
+	reMacro     = regexp.MustCompile(`^macro\s+(\w+)\s*\((.*)\)`)
+	reMacroCall = regexp.MustCompile(`^=?\s*call\s+(\w+)\s*\((.*)\)`)
 
 	// Suppress go vet warning.
 	_ = ParseFile
 )
 
 // Initialize parser and parse the template body.
+//
+// Modifiers and condition helpers resolves using the default set.
 func Parse(tpl []byte, keepFmt bool) (tree *Tree, err error) {
-	p := &Parser{
-		tpl:     tpl,
-		keepFmt: keepFmt,
-	}
-	p.cutComments()
-	p.cutFmt()
+	return defaultSet.Parse(tpl, keepFmt)
+}
 
-	// Prepare template tree.
-	tree = &Tree{}
-	target := newTarget(p)
-	tree.nodes, _, err = p.parseTpl(tree.nodes, 0, target)
-	return
+// Parse the template body in strict mode.
+//
+// Unlike Parse(), which silently skips unknown modifiers and leaves unknown condition helpers to fail at render time,
+// strict mode resolves all modifiers and condition helpers at parse time and returns ParseError on unknown names.
+func ParseStrict(tpl []byte, keepFmt bool) (tree *Tree, err error) {
+	return defaultSet.ParseStrict(tpl, keepFmt)
+}
+
+// Parse the template body and collect all errors instead of stopping on the first one.
+//
+// Besides syntax errors it reports unknown modifiers and condition helpers, unbalanced end tags and control structures
+// placed outside of their parents (like "break" outside of loops). Errors returns as ParseErrors list.
+func ParseAll(tpl []byte, keepFmt bool) (tree *Tree, err error) {
+	return defaultSet.ParseAll(tpl, keepFmt)
 }
 
 // Initialize parser and parse file contents.
 func ParseFile(fileName string, keepFmt bool) (tree *Tree, err error) {
+	return defaultSet.ParseFile(fileName, keepFmt)
+}
+
+// Parse the template body using modifiers and condition helpers of the set.
+//
+// See Parse().
+func (s *Set) Parse(tpl []byte, keepFmt bool) (tree *Tree, err error) {
+	return s.parse(tpl, keepFmt, 0)
+}
+
+// Parse the template body in strict mode using modifiers and condition helpers of the set.
+//
+// See ParseStrict().
+func (s *Set) ParseStrict(tpl []byte, keepFmt bool) (tree *Tree, err error) {
+	return s.parse(tpl, keepFmt, modeStrict)
+}
+
+// Parse the template body and collect all errors using modifiers and condition helpers of the set.
+//
+// See ParseAll().
+func (s *Set) ParseAll(tpl []byte, keepFmt bool) (tree *Tree, err error) {
+	return s.parse(tpl, keepFmt, modeCollect|modeStrict)
+}
+
+// Parse file contents using modifiers and condition helpers of the set.
+func (s *Set) ParseFile(fileName string, keepFmt bool) (tree *Tree, err error) {
 	_, err = os.Stat(fileName)
 	if os.IsNotExist(err) {
 		return
@@ -162,12 +244,40 @@ func ParseFile(fileName string, keepFmt bool) (tree *Tree, err error) {
 	if err != nil {
 		return nil, fmt.Errorf("couldn't read file %s", fileName)
 	}
-	return Parse(raw, keepFmt)
+	return s.Parse(raw, keepFmt)
+}
+
+// Initialize parser in given mode and parse the template body.
+func (s *Set) parse(tpl []byte, keepFmt bool, mode int) (tree *Tree, err error) {
+	start := time.Now()
+	p := &Parser{
+		set:     s,
+		tpl:     tpl,
+		src:     tpl,
+		keepFmt: keepFmt,
+		collect: mode&modeCollect != 0,
+		strict:  mode&modeStrict != 0,
+	}
+	p.cutComments()
+	p.cutFmt()
+
+	// Prepare template tree.
+	tree = &Tree{src: tpl, keepFmt: keepFmt}
+	target := newTarget(p)
+	tree.nodes, _, err = p.parseTpl(tree.nodes, 0, target)
+	if err == nil && p.collect {
+		err = p.finish()
+	}
+	if err == nil {
+		tree.prepare()
+		tree.dur = time.Since(start)
+	}
+	return
 }
 
 // Remove all comments from the template body.
 func (p *Parser) cutComments() {
-	p.tpl = reCutComments.ReplaceAll(p.tpl, empty)
+	p.cut(reCutComments)
 }
 
 // Remove template formatting if needed.
@@ -175,8 +285,8 @@ func (p *Parser) cutFmt() {
 	if p.keepFmt {
 		return
 	}
-	p.tpl = reCutFmt.ReplaceAll(p.tpl, empty)
-	p.tpl = bytealg.Trim(p.tpl, noFmt)
+	p.cut(reCutFmt)
+	p.trim(noFmt)
 }
 
 // Initial parsing method.
@@ -193,7 +303,11 @@ func (p *Parser) parseTpl(nodes []Node, offset int, target *target) ([]Node, int
 		i = bytealg.IndexAt(p.tpl, ctlOpen, i)
 		if i < 0 {
 			if inCtl {
-				return nodes, o, ErrUnexpectedEOF
+				if err = p.fail(p.newError(ParseErrUnexpectedEOF, ErrUnexpectedEOF, o, -1)); err != nil {
+					return nodes, o, err
+				}
+				o = len(p.tpl)
+				break
 			}
 			nodes = addRaw(nodes, p.tpl[o:])
 			o = len(p.tpl)
@@ -203,11 +317,19 @@ func (p *Parser) parseTpl(nodes []Node, offset int, target *target) ([]Node, int
 			// We are inside control structure.
 			e := bytealg.IndexAt(p.tpl, ctlClose, i)
 			if e < 0 {
-				return nodes, o, ErrUnexpectedEOF
+				if err = p.fail(p.newError(ParseErrUnexpectedEOF, ErrUnexpectedEOF, o, -1)); err != nil {
+					return nodes, o, err
+				}
+				o = len(p.tpl)
+				break
 			}
 			e += 2
 			node := Node{}
 			nodes, e, up, err = p.processCtl(nodes, &node, p.tpl[o:e], o)
+			if err == nil && !p.collect && len(p.errs) > 0 {
+				// Strict mode error caught.
+				err = p.errs[0]
+			}
 			if err != nil {
 				return nodes, o, err
 			}
@@ -235,7 +357,18 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 	)
 
 	up = false
+	p.pos, p.end = pos, pos+len(ctl)
+	root.srcOff, root.srcEnd = p.srcPos(pos), p.srcPos(pos+len(ctl)-1)+1
 	t := bytealg.Trim(ctl, ctlTrim)
+	// Check macro call, must be checked before print structure since call may use print syntax.
+	if m := reMacroCall.FindSubmatch(t); m != nil {
+		root.typ = TypeCall
+		root.macro = m[1]
+		root.macroArg = p.extractArgs(bytealg.Trim(m[2], space))
+		nodes = addNode(nodes, *root)
+		offset = pos + len(ctl)
+		return nodes, offset, up, err
+	}
 	// Check tpl (print) structure.
 	if reTplPS.Match(t) || reTplP.Match(t) || reTplS.Match(t) || reTpl.Match(t) {
 		// Sequentially check print structure from the complex to the simplest.
@@ -337,62 +470,60 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 		return nodes, offset, up, err
 	}
 
-	// Check condition structure.
-	if reCond.Match(t) {
-		// Check complexity of the condition first.
-		if reCondComplex.Match(t) {
-			// Check if condition may handled by the condition helper.
-			if m := reCondHelper.FindSubmatch(t); m != nil {
-				target := newTarget(p)
-				p.cc++
-
-				subNodes := make([]Node, 0)
-				subNodes, offset, err = p.parseTpl(subNodes, pos+len(ctl), target)
-				split := splitNodes(subNodes)
-
-				root.typ = TypeCond
-				root.condHlp = m[1]
-				root.condHlpArg = p.extractArgs(m[2])
-				root.condL, root.condR, root.condStaticL, root.condStaticR, root.condOp = p.parseCondExpr(t)
-				if len(split) > 0 {
-					nodeTrue := Node{typ: TypeCondTrue, child: split[0]}
-					root.child = append(root.child, nodeTrue)
-				}
-				if len(split) > 1 {
-					nodeFalse := Node{typ: TypeCondFalse, child: split[1]}
-					root.child = append(root.child, nodeFalse)
-				}
+	// Check condition's else-if branch.
+	// Must be checked before condition structure since "if" is a part of "elseif"/"elif".
+	if m := reCondElif.FindSubmatch(t); m != nil {
+		root.typ = TypeCondElif
+		if n := len(p.conds); n == 0 || p.conds[n-1].depth != p.depth() || p.conds[n-1].els {
+			// Branch is placed outside of condition or after else branch.
+			code, e := ParseErrStray, ErrStrayElif
+			if n > 0 && p.conds[n-1].depth == p.depth() {
+				code, e = ParseErrCond, ErrElifAfterElse
+			}
+			if err = p.fail(p.newError(code, e, pos, pos+len(ctl))); err != nil {
+				return nodes, pos, up, err
+			}
+			offset = pos + len(ctl)
+			return nodes, offset, up, err
+		}
+		if !p.parseCond(root, m[1]) {
+			if err = p.fail(p.newError(ParseErrCond, ErrBadCond, pos, pos+len(ctl))); err != nil {
+				return nodes, pos, up, err
+			}
+		}
+		nodes = addNode(nodes, *root)
+		offset = pos + len(ctl)
+		return nodes, offset, up, err
+	}
 
-				nodes = addNode(nodes, *root)
-				return nodes, offset, up, err
-			} else {
-				return nodes, pos, up, fmt.Errorf("too complex condition '%s' at offset %d", t, pos)
+	// Check condition structure.
+	if m := reCond.FindSubmatch(t); m != nil {
+		root.typ = TypeCond
+		if !p.parseCond(root, m[1]) {
+			if err = p.fail(p.newError(ParseErrCond, ErrBadCond, pos, pos+len(ctl))); err != nil {
+				return nodes, pos, up, err
 			}
 		}
 		// Create new target, increase condition counter and dive deeper.
 		target := newTarget(p)
 		p.cc++
+		p.open(targetCond)
 
 		subNodes := make([]Node, 0)
+		p.conds = append(p.conds, condFrame{depth: p.depth()})
 		subNodes, offset, err = p.parseTpl(subNodes, pos+len(ctl), target)
-		split := splitNodes(subNodes)
-
-		root.typ = TypeCond
-		root.condL, root.condR, root.condStaticL, root.condStaticR, root.condOp = p.parseCondExpr(t)
-		if len(split) > 0 {
-			nodeTrue := Node{typ: TypeCondTrue, child: split[0]}
-			root.child = append(root.child, nodeTrue)
-		}
-		if len(split) > 1 {
-			nodeFalse := Node{typ: TypeCondFalse, child: split[1]}
-			root.child = append(root.child, nodeFalse)
-		}
+		p.conds = p.conds[:len(p.conds)-1]
+		root.child = rollupCondNodes(subNodes)
 
 		nodes = addNode(nodes, *root)
 		return nodes, offset, up, err
 	}
 	// Check condition divider.
 	if bytes.Equal(t, condElse) {
+		p.inside(targetCond, true)
+		if n := len(p.conds); n > 0 && p.conds[n-1].depth == p.depth() {
+			p.conds[n-1].els = true
+		}
 		root.typ = TypeDiv
 		nodes = addNode(nodes, *root)
 		offset = pos + len(ctl)
@@ -401,8 +532,11 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 	// Check condition end.
 	if bytes.Equal(t, condEnd) {
 		// End of condition caught. Decrease the counter and exit.
-		p.cc--
 		offset = pos + len(ctl)
+		if !p.close(targetCond) {
+			return nodes, offset, up, err
+		}
+		p.cc--
 		up = true
 		return nodes, offset, up, err
 	}
@@ -440,12 +574,16 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 				root.loopSep = m[6]
 			}
 		} else {
-			return nodes, 0, up, fmt.Errorf("couldn't parse loop control structure '%s' at offset %d", t, pos)
+			if err = p.fail(p.newError(ParseErrLoop, ErrBadLoop, pos, pos+len(ctl))); err != nil {
+				return nodes, 0, up, err
+			}
+			root.typ = TypeLoopRange
 		}
 
 		// Create new target, increase loop counter and dive deeper.
 		target := newTarget(p)
 		p.cl++
+		p.open(targetLoop)
 
 		root.child = make([]Node, 0)
 		root.child, offset, err = p.parseTpl(root.child, pos+len(ctl), target)
@@ -456,13 +594,17 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 	// Check loop end.
 	if bytes.Equal(t, loopEnd) {
 		// End of loop caught. Decrease the counter and exit.
-		p.cl--
 		offset = pos + len(ctl)
+		if !p.close(targetLoop) {
+			return nodes, offset, up, err
+		}
+		p.cl--
 		up = true
 		return nodes, offset, up, err
 	}
 	// Check loop break.
 	if bytes.Equal(t, loopBrk) {
+		p.inside(targetLoop, false)
 		root.typ = TypeBreak
 		nodes = addNode(nodes, *root)
 		offset = pos + len(ctl)
@@ -470,6 +612,7 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 	}
 	// Check loop continue.
 	if bytes.Equal(t, loopCnt) {
+		p.inside(targetLoop, false)
 		root.typ = TypeContinue
 		nodes = addNode(nodes, *root)
 		offset = pos + len(ctl)
@@ -481,22 +624,53 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 		// Create new target, increase switch counter and dive deeper.
 		target := newTarget(p)
 		p.cs++
+		p.open(targetSwitch)
 
 		root.typ = TypeSwitch
 		if len(m) > 0 {
 			root.switchArg = m[1]
 		}
 		root.child = make([]Node, 0)
+		p.swArg = append(p.swArg, len(root.switchArg) > 0)
 		root.child, offset, err = p.parseTpl(root.child, pos+len(ctl), target)
+		p.swArg = p.swArg[:len(p.swArg)-1]
 		root.child = rollupSwitchNodes(root.child)
 
 		nodes = addNode(nodes, *root)
 		return nodes, offset, up, err
 	}
+	// Check switch's case with complex condition.
+	if reSwitchCaseComplex.Match(t) {
+		p.inside(targetSwitch, true)
+		root.typ = TypeCase
+		if n := len(p.swArg); n > 0 && p.swArg[n-1] {
+			// Complex condition doesn't depend on switch argument, so it's senseless in switch with argument.
+			if err = p.fail(p.newError(ParseErrCase, ErrCaseComplex, pos, pos+len(ctl))); err != nil {
+				return nodes, pos, up, err
+			}
+			offset = pos + len(ctl)
+			return nodes, offset, up, err
+		}
+		expr, ok := p.parseCondLogic(t[len(swCase):])
+		if !ok {
+			if err = p.fail(p.newError(ParseErrCase, ErrBadCase, pos, pos+len(ctl))); err != nil {
+				return nodes, pos, up, err
+			}
+		} else {
+			p.resolveHlp(expr)
+			p.setCaseExpr(root, expr)
+		}
+		nodes = addNode(nodes, *root)
+		offset = pos + len(ctl)
+		return nodes, offset, up, err
+	}
 	// Check switch's case with condition helper.
 	if m := reSwitchCaseHelper.FindSubmatch(t); m != nil {
+		p.inside(targetSwitch, true)
 		root.typ = TypeCase
-		root.caseHlp = m[1]
+		hlp := &condExpr{typ: exprHlp, hlp: m[1]}
+		p.resolveHlp(hlp)
+		root.caseHlp, root.caseHlpFn = hlp.hlp, hlp.hlpFn
 		root.caseHlpArg = p.extractArgs(m[2])
 		nodes = addNode(nodes, *root)
 		offset = pos + len(ctl)
@@ -504,6 +678,7 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 	}
 	// Check switch's case with simple condition.
 	if reSwitchCase.Match(t) {
+		p.inside(targetSwitch, true)
 		root.typ = TypeCase
 		root.caseL, root.caseR, root.caseStaticL, root.caseStaticR, root.caseOp = p.parseCaseExpr(t)
 		nodes = addNode(nodes, *root)
@@ -512,6 +687,7 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 	}
 	// Check switch's default.
 	if bytes.Equal(t, swDefault) {
+		p.inside(targetSwitch, true)
 		root.typ = TypeDefault
 		nodes = addNode(nodes, *root)
 		offset = pos + len(ctl)
@@ -520,8 +696,11 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 	// Check switch end.
 	if bytes.Equal(t, swEnd) {
 		// End of switch caught. Decrease the counter and exit.
-		p.cs--
 		offset = pos + len(ctl)
+		if !p.close(targetSwitch) {
+			return nodes, offset, up, err
+		}
+		p.cs--
 		up = true
 		return nodes, offset, up, err
 	}
@@ -576,16 +755,119 @@ func (p *Parser) processCtl(nodes []Node, root *Node, ctl []byte, pos int) ([]No
 		return nodes, offset, up, err
 	}
 
+	// Check extends.
+	if m := reExtends.FindSubmatch(t); m != nil {
+		root.typ = TypeExtends
+		root.tpl = [][]byte{bytealg.Trim(m[1], quotes)}
+		nodes = addNode(nodes, *root)
+		offset = pos + len(ctl)
+		return nodes, offset, up, err
+	}
+	// Check block structure.
+	if m := reBlock.FindSubmatch(t); m != nil {
+		// Create new target, increase block counter and dive deeper.
+		target := newTarget(p)
+		p.cb++
+		p.open(targetBlock)
+
+		root.typ = TypeBlock
+		root.block = m[1]
+		root.child = make([]Node, 0)
+		root.child, offset, err = p.parseTpl(root.child, pos+len(ctl), target)
+
+		nodes = addNode(nodes, *root)
+		return nodes, offset, up, err
+	}
+	// Check block end.
+	if reBlockEnd.Match(t) {
+		// End of block caught. Decrease the counter and exit.
+		offset = pos + len(ctl)
+		if !p.close(targetBlock) {
+			return nodes, offset, up, err
+		}
+		p.cb--
+		up = true
+		return nodes, offset, up, err
+	}
+	// Check parent block call.
+	if bytes.Equal(t, blockSuper) {
+		root.typ = TypeSuper
+		nodes = addNode(nodes, *root)
+		offset = pos + len(ctl)
+		return nodes, offset, up, err
+	}
+
+	// Check macro structure.
+	if m := reMacro.FindSubmatch(t); m != nil {
+		// Create new target, increase macro counter and dive deeper.
+		target := newTarget(p)
+		p.cm++
+		p.open(targetMacro)
+
+		root.typ = TypeMacro
+		root.macro = m[1]
+		root.macroParam = p.extractNamedArgs(m[2])
+		root.child = make([]Node, 0)
+		root.child, offset, err = p.parseTpl(root.child, pos+len(ctl), target)
+
+		nodes = addNode(nodes, *root)
+		return nodes, offset, up, err
+	}
+	// Check macro end.
+	if bytes.Equal(t, macroEnd) {
+		// End of macro caught. Decrease the counter and exit.
+		offset = pos + len(ctl)
+		if !p.close(targetMacro) {
+			return nodes, offset, up, err
+		}
+		p.cm--
+		up = true
+		return nodes, offset, up, err
+	}
+
 	// Check include.
 	if m := reInc.FindSubmatch(t); m != nil {
 		root.typ = TypeInclude
+		if mw := reIncWith.FindSubmatch(m[1]); mw != nil {
+			// Include with arguments and/or isolated scope.
+			m[1] = mw[1]
+			root.incArg = p.extractNamedArgs(mw[2])
+			for _, a := range root.incArg {
+				if a.val == nil {
+					// Argument without value takes variable with the same name.
+					a.val = &arg{val: a.name}
+				}
+			}
+			root.incOnly = len(mw[3]) > 0
+		}
 		root.tpl = bytes.Split(m[1], space)
 		nodes = addNode(nodes, *root)
 		offset = pos + len(ctl)
 		return nodes, offset, up, err
 	}
 
-	return nodes, 0, up, fmt.Errorf("unknown control structure '%s' at offset %d", t, pos)
+	if err = p.fail(p.newError(ParseErrUnknownCtl, ErrUnknownCtl, pos, pos+len(ctl))); err != nil {
+		return nodes, 0, up, err
+	}
+	offset = pos + len(ctl)
+	return nodes, offset, up, err
+}
+
+// Parse condition expression and fill condition fields of the node.
+func (p *Parser) parseCond(root *Node, expr []byte) bool {
+	// Check complexity of the condition first.
+	if reCondComplex.Match(expr) {
+		// Build expression tree of the condition.
+		e, ok := p.parseCondLogic(expr)
+		if !ok {
+			return false
+		}
+		p.resolveHlp(e)
+		p.setCondExpr(root, e)
+		return true
+	}
+	root.condL, root.condR, root.condStaticL, root.condStaticR, root.condOp = p.parseCondExpr(expr)
+	return true
 }
 
 // Parse condition to left/right parts and condition operator.
@@ -600,6 +882,30 @@ func (p *Parser) parseCondExpr(expr []byte) (l, r []byte, sl, sr bool, op Op) {
 	return
 }
 
+// Move simple condition expression to node's condition fields, complex expressions keeps as is.
+func (p *Parser) setCondExpr(root *Node, expr *condExpr) {
+	switch expr.typ {
+	case exprHlp:
+		root.condHlp, root.condHlpArg, root.condHlpFn = expr.hlp, expr.hlpArg, expr.hlpFn
+	case exprCmp:
+		root.condL, root.condR, root.condStaticL, root.condStaticR, root.condOp = expr.l, expr.r, expr.sl, expr.sr, expr.op
+	default:
+		root.condExpr = expr
+	}
+}
+
+// Move simple case expression to node's case fields, complex expressions keeps as is.
+func (p *Parser) setCaseExpr(root *Node, expr *condExpr) {
+	switch expr.typ {
+	case exprHlp:
+		root.caseHlp, root.caseHlpArg, root.caseHlpFn = expr.hlp, expr.hlpArg, expr.hlpFn
+	case exprCmp:
+		root.caseL, root.caseR, root.caseStaticL, root.caseStaticR, root.caseOp = expr.l, expr.r, expr.sl, expr.sr, expr.op
+	default:
+		root.caseExpr = expr
+	}
+}
+
 // Parse case condition similar to condition parsing.
 func (p *Parser) parseCaseExpr(expr []byte) (l, r []byte, sl, sr bool, op Op) {
 	if m := reSwitchCase.FindSubmatch(expr); m != nil {
@@ -654,8 +960,9 @@ func (p *Parser) extractMods(t, outm []byte) ([]byte, []mod) {
 		}
 		for i := idx; i < len(chunks); i++ {
 			if m := reMod.FindSubmatch(chunks[i]); m != nil {
-				fn := GetModFn(fastconv.B2S(m[1]))
+				fn := p.set.GetModFn(fastconv.B2S(m[1]))
 				if fn == nil {
+					p.failCtl(ParseErrUnknownMod, ErrModNotFound)
 					continue
 				}
 				args := p.extractArgs(m[2])
@@ -681,7 +988,7 @@ func (p *Parser) extractMods(t, outm []byte) ([]byte, []mod) {
 		}
 		// - {%j= ... %} - JSON escape.
 		if a, ok := checkEqMany(outm, outmJ); ok {
-			fn := GetModFn("jsonEscape")
+			fn := p.set.GetModFn("jsonEscape")
 			mods = append(mods, mod{
 				id:  idJ,
 				fn:  fn,
@@ -690,7 +997,7 @@ func (p *Parser) extractMods(t, outm []byte) ([]byte, []mod) {
 		}
 		// - {%q= ... %} - JSON quote.
 		if a, ok := checkEqMany(outm, outmQ); ok {
-			fn := GetModFn("jsonQuote")
+			fn := p.set.GetModFn("jsonQuote")
 			mods = append(mods, mod{
 				id:  idQ,
 				fn:  fn,
@@ -699,7 +1006,7 @@ func (p *Parser) extractMods(t, outm []byte) ([]byte, []mod) {
 		}
 		// - {%h= ... %} - HTML escape.
 		if a, ok := checkEqMany(outm, outmH); ok {
-			fn := GetModFn("htmlEscape")
+			fn := p.set.GetModFn("htmlEscape")
 			mods = append(mods, mod{
 				id:  idH,
 				fn:  fn,
@@ -708,7 +1015,7 @@ func (p *Parser) extractMods(t, outm []byte) ([]byte, []mod) {
 		}
 		// - {%u= ... %} - URL encode.
 		if a, ok := checkEqMany(outm, outmU); ok {
-			fn := GetModFn("urlEncode")
+			fn := p.set.GetModFn("urlEncode")
 			mods = append(mods, mod{
 				id:  idU,
 				fn:  fn,
@@ -719,19 +1026,19 @@ func (p *Parser) extractMods(t, outm []byte) ([]byte, []mod) {
 			switch m[1][0] {
 			case byte(outmf):
 				// - {%f.<prec>= ... %} - Float with precision.
-				fn := GetModFn("floorPrec")
+				fn := p.set.GetModFn("floorPrec")
 				mods = append(mods, mod{
 					id:  idf,
 					fn:  fn,
-					arg: []*arg{{m[2], true}},
+					arg: []*arg{{val: m[2], static: true}},
 				})
 			case byte(outmF):
 				// - {%F.<prec>= ... %} - Ceil rounded to precision float.
-				fn := GetModFn("ceilPrec")
+				fn := p.set.GetModFn("ceilPrec")
 				mods = append(mods, mod{
 					id:  idF,
 					fn:  fn,
-					arg: []*arg{{m[2], true}},
+					arg: []*arg{{val: m[2], static: true}},
 				})
 			}
 		}
@@ -755,7 +1062,7 @@ func (p *Parser) extractArgs(l []byte) []*arg {
 	if len(l) == 0 {
 		return r
 	}
-	args := bytes.Split(l, comma)
+	args := splitArgList(l)
 	for _, a := range args {
 		a = bytealg.Trim(a, space)
 		r = append(r, &arg{
@@ -766,25 +1073,92 @@ func (p *Parser) extractArgs(l []byte) []*arg {
 	return r
 }
 
+// Get list of named arguments, ex:
+// {% macro name(arg0, arg1="default", ..., argN=var0) %}
+// {% include tplID with arg0=var0, ..., argN="static" %}
+func (p *Parser) extractNamedArgs(l []byte) []*namedArg {
+	r := make([]*namedArg, 0)
+	l = bytealg.Trim(l, space)
+	if len(l) == 0 {
+		return r
+	}
+	args := splitArgList(l)
+	for _, a := range args {
+		na := namedArg{}
+		if i := bytes.IndexByte(a, '='); i != -1 {
+			val := bytealg.Trim(a[i+1:], space)
+			na.val = &arg{
+				val:    bytealg.Trim(val, quotes),
+				static: isStatic(val),
+			}
+			a = a[:i]
+		}
+		na.name = bytealg.Trim(a, space)
+		r = append(r, &na)
+	}
+	return r
+}
+
+// Split list of arguments by commas.
+//
+// Commas inside quotes and parentheses don't split the list, ex: title="a, b", f(x, y).
+func splitArgList(l []byte) [][]byte {
+	r := make([][]byte, 0, 4)
+	var (
+		q    byte
+		d, o int
+	)
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		switch {
+		case q != 0:
+			if c == q {
+				q = 0
+			}
+		case bytes.IndexByte(quotes, c) != -1:
+			q = c
+		case c == '(':
+			d++
+		case c == ')':
+			d--
+		case c == ',' && d == 0:
+			r = append(r, l[o:i])
+			o = i + 1
+		}
+	}
+	return append(r, l[o:])
+}
+
 // Create new target based on current parser state.
 func newTarget(p *Parser) *target {
 	return &target{
 		targetCond:   p.cc,
 		targetLoop:   p.cl,
 		targetSwitch: p.cs,
+		targetBlock:  p.cb,
+		targetMacro:  p.cm,
 	}
 }
 
+// Get total depth of all control structures.
+func (p *Parser) depth() int {
+	return p.cc + p.cl + p.cs + p.cb + p.cm
+}
+
 // Check if parser reached the target.
 func (t *target) reached(p *Parser) bool {
 	return (*t)[targetCond] == p.cc &&
 		(*t)[targetLoop] == p.cl &&
-		(*t)[targetSwitch] == p.cs
+		(*t)[targetSwitch] == p.cs &&
+		(*t)[targetBlock] == p.cb &&
+		(*t)[targetMacro] == p.cm
 }
 
 // Check if target is a root.
 func (t *target) eqZero() bool {
 	return (*t)[targetCond] == 0 &&
 		(*t)[targetLoop] == 0 &&
-		(*t)[targetSwitch] == 0
+		(*t)[targetSwitch] == 0 &&
+		(*t)[targetBlock] == 0 &&
+		(*t)[targetMacro] == 0
 }
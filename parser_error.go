@@ -0,0 +1,286 @@
+package dyntpl
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/koykov/bytealg"
+	"github.com/koykov/fastconv"
+)
+
+// Code of the parse error.
+//
+// Codes are stable and may be used by external tools (e.g. editors) to distinguish errors.
+type ParseErrCode int
+
+const (
+	// Known codes of parse errors.
+	ParseErrUnknown       ParseErrCode = 0
+	ParseErrUnexpectedEOF ParseErrCode = 1
+	ParseErrUnknownCtl    ParseErrCode = 2
+	ParseErrCond          ParseErrCode = 3
+	ParseErrLoop          ParseErrCode = 4
+	ParseErrCase          ParseErrCode = 5
+	ParseErrUnknownMod    ParseErrCode = 6
+	ParseErrUnknownHlp    ParseErrCode = 7
+	ParseErrUnbalanced    ParseErrCode = 8
+	ParseErrStray         ParseErrCode = 9
+	ParseErrUndefinedVar  ParseErrCode = 10
+	ParseErrNotIterable   ParseErrCode = 11
+	ParseErrSenseless     ParseErrCode = 12
+	ParseErrUnreachable   ParseErrCode = 13
+)
+
+// ParseError describes the error of parsing (or validation, see Validate()) with position of the failing construct in the
+// original template source.
+type ParseError struct {
+	Code ParseErrCode
+	// Base error, e.g. ErrUnexpectedEOF or ErrUnknownCtl.
+	Err error
+	// Byte offset in the source, line and column (in runes) of the construct. Line and column starts from 1.
+	Offset, Line, Column int
+	// Failing construct, e.g. "{% foo %}".
+	Construct string
+	// Source line that contains the construct.
+	Snippet string
+}
+
+// ParseErrors is a list of all errors found by ParseAll.
+type ParseErrors []*ParseError
+
+// Opened control structure, see Parser.open().
+type ctlFrame struct {
+	kind     int
+	pos, end int
+}
+
+// Span of the template removed by cutComments() or cutFmt().
+type cutSpan struct {
+	// Position in the text after removing and total length of spans removed up to this one (inclusive).
+	pos, ln int
+}
+
+// String view of the code.
+func (c ParseErrCode) String() string {
+	switch c {
+	case ParseErrUnexpectedEOF:
+		return "unexpected-eof"
+	case ParseErrUnknownCtl:
+		return "unknown-ctl"
+	case ParseErrCond:
+		return "bad-cond"
+	case ParseErrLoop:
+		return "bad-loop"
+	case ParseErrCase:
+		return "bad-case"
+	case ParseErrUnknownMod:
+		return "unknown-mod"
+	case ParseErrUnknownHlp:
+		return "unknown-cond-helper"
+	case ParseErrUnbalanced:
+		return "unbalanced-ctl"
+	case ParseErrStray:
+		return "stray-ctl"
+	case ParseErrUndefinedVar:
+		return "undefined-var"
+	case ParseErrNotIterable:
+		return "not-iterable"
+	case ParseErrSenseless:
+		return "senseless-cond"
+	case ParseErrUnreachable:
+		return "unreachable"
+	default:
+		return "unknown"
+	}
+}
+
+// Get error message.
+func (e *ParseError) Error() string {
+	return e.Err.Error() + " '" + e.Construct + "' at line " + strconv.Itoa(e.Line) + ", column " +
+		strconv.Itoa(e.Column)
+}
+
+// Get base error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Get error message.
+func (e ParseErrors) Error() string {
+	var buf bytes.Buffer
+	for i, err := range e {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
+
+// Register the error.
+//
+// Error returns back in default mode to stop parsing, in collect mode it stores to the list and parsing continues.
+func (p *Parser) fail(err *ParseError) error {
+	if !p.collect {
+		return err
+	}
+	p.errs = append(p.errs, err)
+	return nil
+}
+
+// Register the error of the current control structure in collect or strict mode.
+func (p *Parser) failCtl(code ParseErrCode, err error) {
+	if p.collect || p.strict {
+		p.errs = append(p.errs, p.newError(code, err, p.pos, p.end))
+	}
+}
+
+// Open control structure of given kind (see target* constants).
+func (p *Parser) open(kind int) {
+	if p.collect {
+		p.stack = append(p.stack, ctlFrame{kind: kind, pos: p.pos, end: p.end})
+	}
+}
+
+// Close control structure of given kind.
+//
+// Returns false in collect mode if the end tag doesn't close the innermost structure. Such tag should be ignored.
+func (p *Parser) close(kind int) bool {
+	if !p.collect {
+		return true
+	}
+	if n := len(p.stack); n == 0 || p.stack[n-1].kind != kind {
+		p.failCtl(ParseErrUnbalanced, ErrUnbalancedCtl)
+		return false
+	}
+	p.stack = p.stack[:len(p.stack)-1]
+	return true
+}
+
+// Check if the current control structure is placed inside structure of given kind.
+//
+// Innermost flag means that the structure should be the closest parent, otherwise any parent is acceptable.
+func (p *Parser) inside(kind int, innermost bool) {
+	if !p.collect {
+		return
+	}
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		if p.stack[i].kind == kind {
+			return
+		}
+		if innermost {
+			break
+		}
+	}
+	p.failCtl(ParseErrStray, ErrStrayCtl)
+}
+
+// Resolve condition helpers used in the expression.
+//
+// Unknown helpers are reported in strict mode, otherwise they fail at render time.
+func (p *Parser) resolveHlp(e *condExpr) {
+	if e == nil {
+		return
+	}
+	if e.typ == exprHlp {
+		if e.hlpFn = p.set.GetCondFn(fastconv.B2S(e.hlp)); e.hlpFn == nil && (p.collect || p.strict) {
+			p.failCtl(ParseErrUnknownHlp, ErrCondHlpNotFound)
+		}
+	}
+	p.resolveHlp(e.left)
+	p.resolveHlp(e.right)
+}
+
+// Register errors of structures that wasn't closed and sort errors by position.
+func (p *Parser) finish() error {
+	for _, f := range p.stack {
+		p.errs = append(p.errs, p.newError(ParseErrUnbalanced, ErrUnclosedCtl, f.pos, f.end))
+	}
+	if len(p.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(p.errs, func(i, j int) bool {
+		return p.errs[i].Offset < p.errs[j].Offset
+	})
+	return p.errs
+}
+
+// Remove all matches of the regexp from the template and keep removed spans to restore original positions.
+func (p *Parser) cut(re *regexp.Regexp) {
+	idx := re.FindAllIndex(p.tpl, -1)
+	if len(idx) == 0 {
+		return
+	}
+	var (
+		buf   = make([]byte, 0, len(p.tpl))
+		spans = make([]cutSpan, 0, len(idx))
+		o, ln int
+	)
+	for _, m := range idx {
+		buf = append(buf, p.tpl[o:m[0]]...)
+		ln += m[1] - m[0]
+		spans = append(spans, cutSpan{pos: len(buf), ln: ln})
+		o = m[1]
+	}
+	p.tpl = append(buf, p.tpl[o:]...)
+	p.cuts = append(p.cuts, spans)
+}
+
+// Remove leading and trailing symbols and keep removed leading span.
+func (p *Parser) trim(cutset []byte) {
+	if ln := len(p.tpl) - len(bytes.TrimLeft(p.tpl, string(cutset))); ln > 0 {
+		p.cuts = append(p.cuts, []cutSpan{{pos: 0, ln: ln}})
+	}
+	p.tpl = bytealg.Trim(p.tpl, cutset)
+}
+
+// Convert position in the processed template to the position in the original source.
+//
+// Position of each control construct converts, so spans keep total lengths to search the last span before position.
+func (p *Parser) srcPos(pos int) int {
+	for i := len(p.cuts) - 1; i >= 0; i-- {
+		spans := p.cuts[i]
+		if j := sort.Search(len(spans), func(j int) bool { return spans[j].pos > pos }); j > 0 {
+			pos += spans[j-1].ln
+		}
+	}
+	return pos
+}
+
+// Make parse error of the construct placed in the processed template at [pos, end).
+func (p *Parser) newError(code ParseErrCode, err error, pos, end int) *ParseError {
+	lo, hi := p.srcPos(pos), -1
+	if end > pos {
+		hi = p.srcPos(end-1) + 1
+	}
+	return srcError(p.src, code, err, lo, hi)
+}
+
+// Make error of the construct placed in the source at [lo, hi).
+func srcError(src []byte, code ParseErrCode, err error, lo, hi int) *ParseError {
+	e := &ParseError{Code: code, Err: err}
+	if lo > len(src) {
+		lo = len(src)
+	}
+	// Line bounds of the construct.
+	ls := bytes.LastIndexByte(src[:lo], '\n') + 1
+	le := bytes.IndexByte(src[lo:], '\n')
+	if le == -1 {
+		le = len(src)
+	} else {
+		le += lo
+	}
+	if hi <= lo || hi > len(src) {
+		// Construct isn't closed, take the rest of the line.
+		hi = le
+	}
+	e.Offset = lo
+	e.Line = bytes.Count(src[:lo], []byte("\n")) + 1
+	e.Column = utf8.RuneCount(src[ls:lo]) + 1
+	e.Construct = string(src[lo:hi])
+	e.Snippet = string(src[ls:le])
+	return e
+}
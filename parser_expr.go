@@ -0,0 +1,261 @@
+package dyntpl
+
+import (
+	"bytes"
+
+	"github.com/koykov/bytealg"
+)
+
+// Type of the condition expression token.
+type exprTokenType int
+
+const (
+	// Known types of expression tokens.
+	tokWord exprTokenType = iota
+	tokLP
+	tokRP
+	tokComma
+	tokNot
+	tokAnd
+	tokOr
+	tokOp
+)
+
+// Condition expression token.
+type exprToken struct {
+	typ exprTokenType
+	val []byte
+}
+
+// Condition expression parser.
+//
+// Parses expressions like `a == 1 && (lenGt0(b) || !c)` using the following precedence (from the lowest):
+// * ||
+// * &&
+// * ! and parentheses
+// * comparisons, condition helpers and standalone variables
+type exprParser struct {
+	p   *Parser
+	tok []exprToken
+	pos int
+}
+
+var (
+	// Symbols that terminates the word token.
+	exprStop = []byte(" \t()!,&|<>=")
+)
+
+// Parse condition expression and build the expression tree.
+//
+// Returns false if expression contains syntax errors.
+func (p *Parser) parseCondLogic(expr []byte) (*condExpr, bool) {
+	ep := exprParser{p: p}
+	if !ep.tokenize(expr) || len(ep.tok) == 0 {
+		return nil, false
+	}
+	e, ok := ep.parseOr()
+	if !ok || ep.pos != len(ep.tok) {
+		return nil, false
+	}
+	return e, true
+}
+
+// Split expression to tokens.
+func (ep *exprParser) tokenize(expr []byte) bool {
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			ep.tok = append(ep.tok, exprToken{typ: tokLP, val: expr[i : i+1]})
+			i++
+		case c == ')':
+			ep.tok = append(ep.tok, exprToken{typ: tokRP, val: expr[i : i+1]})
+			i++
+		case c == ',':
+			ep.tok = append(ep.tok, exprToken{typ: tokComma, val: expr[i : i+1]})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(expr) || expr[i+1] != c {
+				return false
+			}
+			typ := tokAnd
+			if c == '|' {
+				typ = tokOr
+			}
+			ep.tok = append(ep.tok, exprToken{typ: typ, val: expr[i : i+2]})
+			i += 2
+		case c == '!' || c == '<' || c == '>' || c == '=':
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				ep.tok = append(ep.tok, exprToken{typ: tokOp, val: expr[i : i+2]})
+				i += 2
+				continue
+			}
+			switch c {
+			case '!':
+				ep.tok = append(ep.tok, exprToken{typ: tokNot, val: expr[i : i+1]})
+			case '<', '>':
+				ep.tok = append(ep.tok, exprToken{typ: tokOp, val: expr[i : i+1]})
+			default:
+				return false
+			}
+			i++
+		case bytes.IndexByte(quotes, c) != -1:
+			// Quoted string, keep quotes to detect static value later.
+			e := bytes.IndexByte(expr[i+1:], c)
+			if e == -1 {
+				return false
+			}
+			e += i + 2
+			ep.tok = append(ep.tok, exprToken{typ: tokWord, val: expr[i:e]})
+			i = e
+		default:
+			// Variable, static value or helper name.
+			o := i
+			for i < len(expr) && bytes.IndexByte(exprStop, expr[i]) == -1 {
+				if expr[i] == '[' {
+					// Square brackets may contain arbitrary symbols, skip them.
+					if i = exprBracketEnd(expr, i); i == -1 {
+						return false
+					}
+				}
+				i++
+			}
+			ep.tok = append(ep.tok, exprToken{typ: tokWord, val: expr[o:i]})
+		}
+	}
+	return true
+}
+
+// Find the position of square bracket that closes the bracket at position i.
+func exprBracketEnd(expr []byte, i int) int {
+	var (
+		depth int
+		quote byte
+	)
+	for ; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case bytes.IndexByte(quotes, c) != -1:
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Check the type of the current token.
+func (ep *exprParser) is(typ exprTokenType) bool {
+	return ep.pos < len(ep.tok) && ep.tok[ep.pos].typ == typ
+}
+
+// Parse logic OR sequence.
+func (ep *exprParser) parseOr() (*condExpr, bool) {
+	l, ok := ep.parseAnd()
+	if !ok {
+		return nil, false
+	}
+	for ep.is(tokOr) {
+		ep.pos++
+		r, ok := ep.parseAnd()
+		if !ok {
+			return nil, false
+		}
+		l = &condExpr{typ: exprOr, left: l, right: r}
+	}
+	return l, true
+}
+
+// Parse logic AND sequence.
+func (ep *exprParser) parseAnd() (*condExpr, bool) {
+	l, ok := ep.parseUnary()
+	if !ok {
+		return nil, false
+	}
+	for ep.is(tokAnd) {
+		ep.pos++
+		r, ok := ep.parseUnary()
+		if !ok {
+			return nil, false
+		}
+		l = &condExpr{typ: exprAnd, left: l, right: r}
+	}
+	return l, true
+}
+
+// Parse negation and parentheses.
+func (ep *exprParser) parseUnary() (*condExpr, bool) {
+	if ep.is(tokNot) {
+		ep.pos++
+		e, ok := ep.parseUnary()
+		if !ok {
+			return nil, false
+		}
+		return &condExpr{typ: exprNot, left: e}, true
+	}
+	if ep.is(tokLP) {
+		ep.pos++
+		e, ok := ep.parseOr()
+		if !ok || !ep.is(tokRP) {
+			return nil, false
+		}
+		ep.pos++
+		return e, true
+	}
+	return ep.parsePrimary()
+}
+
+// Parse comparison, condition helper call or standalone variable.
+func (ep *exprParser) parsePrimary() (*condExpr, bool) {
+	if !ep.is(tokWord) {
+		return nil, false
+	}
+	w := ep.tok[ep.pos].val
+	ep.pos++
+	if ep.is(tokLP) {
+		// Condition helper call.
+		ep.pos++
+		e := &condExpr{typ: exprHlp, hlp: w, hlpArg: make([]*arg, 0)}
+		for !ep.is(tokRP) {
+			if len(e.hlpArg) > 0 {
+				if !ep.is(tokComma) {
+					return nil, false
+				}
+				ep.pos++
+			}
+			if !ep.is(tokWord) {
+				return nil, false
+			}
+			a := ep.tok[ep.pos].val
+			e.hlpArg = append(e.hlpArg, &arg{
+				val:    bytealg.Trim(a, quotes),
+				static: isStatic(a),
+			})
+			ep.pos++
+		}
+		ep.pos++
+		return e, true
+	}
+	if ep.is(tokOp) {
+		// Comparison.
+		op := ep.p.parseOp(ep.tok[ep.pos].val)
+		ep.pos++
+		if !ep.is(tokWord) {
+			return nil, false
+		}
+		r := ep.tok[ep.pos].val
+		ep.pos++
+		return &condExpr{typ: exprCmp, l: w, r: r, sl: isStatic(w), sr: isStatic(r), op: op}, true
+	}
+	return &condExpr{typ: exprVar, l: w, sl: isStatic(w)}, true
+}
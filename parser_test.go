@@ -2,6 +2,7 @@ package dyntpl
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -136,6 +137,48 @@ tpl: brand
 				tpl: user.Name
 				raw: , you should confirm your account first.
 `)
+	condComplexOrigin = []byte(`
+{% if user.Status >= 60 && (user.Finance.AllowBuy || !lenEq0(user.Id)) %}
+	allowed
+{% else %}
+	denied
+{% endif %}
+{% if !(user.Id == 0 || user.Status < 10) && lenGt0(user.Name) %}
+	welcome
+{% endif %}
+`)
+	condComplexExpect = []byte(`cond: expr user.Status >= 60 && (user.Finance.AllowBuy || !lenEq0(user.Id))
+	true: 
+		raw: allowed
+	false: 
+		raw: denied
+cond: expr !(user.Id == 0 || user.Status < 10) && lenGt0(user.Name)
+	true: 
+		raw: welcome
+`)
+	condElifOrigin = []byte(`
+{% if user.Status < 10 %}
+	anonymous
+{% elseif user.Status < 45 %}
+	logged in
+{% elif lenEq0(user.Id) %}
+{% else if user.Status >= 60 && user.Finance.AllowBuy %}
+	privileged
+{% else %}
+	unknown
+{% endif %}
+`)
+	condElifExpect = []byte(`cond: left user.Status op < right 10
+	true: 
+		raw: anonymous
+	elif: left user.Status op < right 45
+		raw: logged in
+	elif: lenEq0(user.Id)
+	elif: expr user.Status >= 60 && user.Finance.AllowBuy
+		raw: privileged
+	false: 
+		raw: unknown
+`)
 
 	loopOrigin = []byte(`
 <h2>Export history</h2>
@@ -275,11 +318,60 @@ switch:
 	case: anonItem(item, "1")
 		raw: "no_data": true
 raw: }]
+`)
+	switchComplexOrigin = []byte(`
+{% switch %}
+{% case item.Index < 0 || item.Index > 10 %}
+	out of range
+{% case !(item.Index == 0) && lenGt0(item.Name) %}
+	{%= item.Name %}
+{% endswitch %}`)
+	switchComplexExpect = []byte(`switch: 
+	case: expr item.Index < 0 || item.Index > 10
+		raw: out of range
+	case: expr !(item.Index == 0) && lenGt0(item.Name)
+		tpl: item.Name
+`)
+	extOrigin = []byte(`{% extends "layout/main" %}
+{% block title %}{% super %} - profile{% endblock %}
+{% block body %}
+	<h1>{% block header %}{%= user.Name %}{% endblock %}</h1>
+{% endblock body %}`)
+	extExpect = []byte(`extends: layout/main 
+block: title
+	super
+	raw:  - profile
+block: body
+	raw: <h1>
+	block: header
+		tpl: user.Name
+	raw: </h1>
+`)
+	macroOrigin = []byte(`{% macro row(item, title="none", sep=defaultSep) %}
+	{%= item.Name %}{%= sep %}
+{% endmacro %}
+{%= call row(user, "foo") %}
+{% call row(user) %}`)
+	macroExpect = []byte(`macro: row(item, title="none", sep=defaultSep)
+	tpl: item.Name
+	tpl: sep
+call: row(user, "foo")
+call: row(user)
+`)
+	macroArgsOrigin = []byte(`{% macro row(title="a, b", sep=",") %}{%= title %}{% endmacro %}{%= call row("f(x)", ", ") %}`)
+	macroArgsExpect = []byte(`macro: row(title="a, b", sep=",")
+	tpl: title
+call: row("f(x)", ", ")
 `)
 	incOrigin = []byte(`foo {% include sidebar/right %} bar`)
 	incExpect = []byte(`raw: foo 
 inc: sidebar/right 
 raw:  bar
+`)
+
+	incArgOrigin = []byte(`{% include row with item=v, idx=k, title="foo", user only %}{% include footer only %}`)
+	incArgExpect = []byte(`inc: row with item=v, idx=k, title="foo", user=user only
+inc: footer only
 `)
 )
 
@@ -316,11 +408,121 @@ func TestParsePrimitive(t *testing.T) {
 
 func TestParseuEOT(t *testing.T) {
 	_, err := Parse(uEOTOrigin, false)
-	if err != ErrUnexpectedEOF {
+	if !errors.Is(err, ErrUnexpectedEOF) {
 		t.Errorf("unexpected EOT fail\nexp: %s\ngot: %s", ErrUnexpectedEOF, err)
 	}
 }
 
+func TestParseError(t *testing.T) {
+	stages := []struct {
+		src                string
+		code               ParseErrCode
+		line, col          int
+		construct, snippet string
+	}{
+		{string(uEOTOrigin), ParseErrUnexpectedEOF, 1, 21, "{%= var1 end", string(uEOTOrigin)},
+		{"{# header #}\n<div>\n\t{# nöte #}<p>{% foo bar %}</p>\n</div>", ParseErrUnknownCtl, 3, 15,
+			"{% foo bar %}", "\t{# nöte #}<p>{% foo bar %}</p>"},
+		{"\n\n  {% if a && (b %}\n{% endif %}", ParseErrCond, 3, 3, "{% if a && (b %}", "  {% if a && (b %}"},
+		// Many cut spans before the construct and right before it.
+		{"{# a #}{# b #}\n\t{# c #}x{# d #}\n\t\t{# e #}{% foo %}{# f #}", ParseErrUnknownCtl, 3, 10,
+			"{% foo %}", "\t\t{# e #}{% foo %}{# f #}"},
+		{"{# a #}{% foo %}", ParseErrUnknownCtl, 1, 8, "{% foo %}", "{# a #}{% foo %}"},
+		// Else-if branch outside of condition and after else branch.
+		{"x{% elif a %}y", ParseErrStray, 1, 2, "{% elif a %}", "x{% elif a %}y"},
+		{"{% if a %}{% for i := 0; i < 2; i++ %}{% elif b %}{% endfor %}{% endif %}", ParseErrStray, 1, 39,
+			"{% elif b %}", "{% if a %}{% for i := 0; i < 2; i++ %}{% elif b %}{% endfor %}{% endif %}"},
+		{"{% if a %}1{% else %}2{% elseif b %}3{% endif %}", ParseErrCond, 1, 23, "{% elseif b %}",
+			"{% if a %}1{% else %}2{% elseif b %}3{% endif %}"},
+		// Complex case doesn't depend on switch argument.
+		{"{% switch x %}\n{% case x == 1 || y %}1{% endswitch %}", ParseErrCase, 2, 1, "{% case x == 1 || y %}",
+			"{% case x == 1 || y %}1{% endswitch %}"},
+	}
+	for _, stage := range stages {
+		_, err := Parse([]byte(stage.src), false)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("parse error expected, got %v", err)
+			continue
+		}
+		if perr.Code != stage.code || perr.Line != stage.line || perr.Column != stage.col ||
+			perr.Construct != stage.construct || perr.Snippet != stage.snippet {
+			t.Errorf("parse error mismatch\nexp: %s %d:%d %q %q\ngot: %s %d:%d %q %q",
+				stage.code, stage.line, stage.col, stage.construct, stage.snippet,
+				perr.Code, perr.Line, perr.Column, perr.Construct, perr.Snippet)
+		}
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	src := []byte(`{% if x.Ok %}
+	{%= x.Name|noSuchMod() %}
+	{% break %}
+{% endfor %}
+{% endif %}
+{% if noSuchHlp(x) && x.Ok %}{% endif %}
+{% else %}
+{% for _, v := range x.List %}
+	{% foo %}`)
+	exp := []struct {
+		code      ParseErrCode
+		line, col int
+	}{
+		{ParseErrUnknownMod, 2, 2},
+		{ParseErrStray, 3, 2},
+		{ParseErrUnbalanced, 4, 1},
+		{ParseErrUnknownHlp, 6, 1},
+		{ParseErrStray, 7, 1},
+		{ParseErrUnbalanced, 8, 1},
+		{ParseErrUnknownCtl, 9, 2},
+	}
+	_, err := ParseAll(src, false)
+	errs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("parse errors expected, got %v", err)
+	}
+	if len(errs) != len(exp) {
+		t.Fatalf("parse errors count mismatch\nexp: %d\ngot: %d\n%s", len(exp), len(errs), errs)
+	}
+	for i, e := range exp {
+		if errs[i].Code != e.code || errs[i].Line != e.line || errs[i].Column != e.col {
+			t.Errorf("parse error #%d mismatch\nexp: %s %d:%d\ngot: %s %d:%d", i, e.code, e.line, e.col,
+				errs[i].Code, errs[i].Line, errs[i].Column)
+		}
+	}
+	if _, err = ParseAll(tplPS, false); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	stages := []struct {
+		src  string
+		code ParseErrCode
+	}{
+		{`{%= x.Name|defualt(0) %}`, ParseErrUnknownMod},
+		{`{% if noSuchHlp(x) %}{% endif %}`, ParseErrUnknownHlp},
+		{`{% switch %}{% case noSuchHlp(x) %}{% endswitch %}`, ParseErrUnknownHlp},
+		{`{%= x.Name|default(0) %}{% if lenGt0(x) %}{% endif %}`, ParseErrUnknown},
+	}
+	for _, stage := range stages {
+		_, err := ParseStrict([]byte(stage.src), false)
+		var perr *ParseError
+		if stage.code == ParseErrUnknown {
+			if err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		if !errors.As(err, &perr) || perr.Code != stage.code {
+			t.Errorf("strict parse error mismatch\nexp: %s\ngot: %v", stage.code, err)
+		}
+		if _, err = Parse([]byte(stage.src), false); err != nil {
+			t.Errorf("lenient parse failed: %s", err)
+		}
+	}
+}
+
 func TestParsePrefixSuffix(t *testing.T) {
 	tree, _ := Parse(tplPS, false)
 	r := tree.HumanReadable()
@@ -383,6 +585,32 @@ func TestParseCondition(t *testing.T) {
 	}
 }
 
+func TestParseConditionComplex(t *testing.T) {
+	tree, err := Parse(condComplexOrigin, false)
+	if err != nil {
+		t.Error(err)
+	}
+	r := tree.HumanReadable()
+	if !bytes.Equal(r, condComplexExpect) {
+		t.Errorf("complex condition test failed\nexp: %s\ngot: %s", string(condComplexExpect), string(r))
+	}
+
+	if _, err = Parse([]byte(`{% if user.Id == 0 && (user.Status > 10 %}foo{% endif %}`), false); err == nil {
+		t.Error("unbalanced condition test failed: error expected")
+	}
+}
+
+func TestParseConditionElif(t *testing.T) {
+	tree, err := Parse(condElifOrigin, false)
+	if err != nil {
+		t.Error(err)
+	}
+	r := tree.HumanReadable()
+	if !bytes.Equal(r, condElifExpect) {
+		t.Errorf("else-if condition test failed\nexp: %s\ngot: %s", string(condElifExpect), string(r))
+	}
+}
+
 func TestParseLoop(t *testing.T) {
 	tree, _ := Parse(loopOrigin, false)
 	r := tree.HumanReadable()
@@ -415,6 +643,52 @@ func TestParseSwitch(t *testing.T) {
 	if !bytes.Equal(rNCH, switchNoCondHelperExpect) {
 		t.Errorf("switch no cond with helper condition test failed\nexp: %s\ngot: %s", string(switchNoCondHelperExpect), string(rNCH))
 	}
+
+	treeC, _ := Parse(switchComplexOrigin, false)
+	rC := treeC.HumanReadable()
+	if !bytes.Equal(rC, switchComplexExpect) {
+		t.Errorf("switch with complex condition test failed\nexp: %s\ngot: %s", string(switchComplexExpect), string(rC))
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	stages := []struct {
+		path   string
+		expect string
+	}{
+		{"user.Name", "user Name"},
+		{"user.History[i].Cost", "user History [i] Cost"},
+		{"items[0]", "items 0"},
+		{"data.Rows[i].Cells[j]", "data Rows [i] Cells [j]"},
+		{"matrix[i][j]", "matrix [i] [j]"},
+		{`attrs["a.b"].val`, "attrs a.b val"},
+		{"users[user.Id]", "users [user Id]"},
+		{"a[b[c]].d", "a [b [c]] d"},
+	}
+	for _, stage := range stages {
+		p := newPath([]byte(stage.path))
+		if r := writePath(p); r != stage.expect {
+			t.Errorf("path test failed\nexp: %s\ngot: %s", stage.expect, r)
+		}
+	}
+}
+
+// Write segments of the path separated by spaces, index expressions wraps with brackets.
+func writePath(p *vpath) string {
+	var buf bytes.Buffer
+	for i, seg := range p.seg {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		if seg.idx != nil {
+			buf.WriteByte('[')
+			buf.WriteString(writePath(seg.idx))
+			buf.WriteByte(']')
+		} else {
+			buf.WriteString(seg.key)
+		}
+	}
+	return buf.String()
 }
 
 func TestParseInclude(t *testing.T) {
@@ -424,3 +698,47 @@ func TestParseInclude(t *testing.T) {
 		t.Errorf("include test failed\nexp: %s\ngot: %s", string(incExpect), string(r))
 	}
 }
+
+func TestParseIncludeArgs(t *testing.T) {
+	tree, _ := Parse(incArgOrigin, false)
+	r := tree.HumanReadable()
+	if !bytes.Equal(r, incArgExpect) {
+		t.Errorf("include with args test failed\nexp: %s\ngot: %s", string(incArgExpect), string(r))
+	}
+}
+
+func TestParseExtends(t *testing.T) {
+	tree, err := Parse(extOrigin, false)
+	if err != nil {
+		t.Error(err)
+	}
+	r := tree.HumanReadable()
+	if !bytes.Equal(r, extExpect) {
+		t.Errorf("extends test failed\nexp: %s\ngot: %s", string(extExpect), string(r))
+	}
+	if string(tree.ext) != "layout/main" || len(tree.blocks) != 3 {
+		t.Error("extends test failed: inheritance data mismatch")
+	}
+}
+
+func TestParseMacro(t *testing.T) {
+	tree, err := Parse(macroOrigin, false)
+	if err != nil {
+		t.Error(err)
+	}
+	r := tree.HumanReadable()
+	if !bytes.Equal(r, macroExpect) {
+		t.Errorf("macro test failed\nexp: %s\ngot: %s", string(macroExpect), string(r))
+	}
+}
+
+func TestParseMacroArgs(t *testing.T) {
+	tree, err := Parse(macroArgsOrigin, false)
+	if err != nil {
+		t.Error(err)
+	}
+	r := tree.HumanReadable()
+	if !bytes.Equal(r, macroArgsExpect) {
+		t.Errorf("macro args test failed\nexp: %s\ngot: %s", string(macroArgsExpect), string(r))
+	}
+}
@@ -0,0 +1,131 @@
+package dyntpl
+
+import (
+	"bytes"
+
+	"github.com/koykov/bytealg"
+	"github.com/koykov/fastconv"
+)
+
+// Variable path split to segments at parse time.
+//
+// Path like user.History[i].Cost splits to segments "user", "History", [i] and "Cost". Segment in square brackets is
+// an index expression, that evaluates at render time, see Ctx.splitPath().
+type vpath struct {
+	seg []vseg
+	// Path contains index expressions.
+	dyn bool
+}
+
+// Segment of variable path.
+type vseg struct {
+	// Static key of the segment.
+	key string
+	// Index expression, evaluated value uses as the key.
+	idx *vpath
+}
+
+// Make new path of the variable.
+func newPath(raw []byte) *vpath {
+	p := &vpath{}
+	return p.parse(raw)
+}
+
+// Parse raw path to segments.
+//
+// Segments slice is reused, so the same object may be used to parse paths on the fly, see Ctx.Get().
+func (p *vpath) parse(raw []byte) *vpath {
+	p.seg, p.dyn = p.seg[:0], false
+	if len(raw) == 0 {
+		return p
+	}
+	o := 0
+	for i := 0; i <= len(raw); i++ {
+		if i < len(raw) && raw[i] == '[' {
+			// Dots inside square brackets doesn't split the path.
+			if e := exprBracketEnd(raw, i); e != -1 {
+				i = e
+				continue
+			}
+		}
+		if i == len(raw) || raw[i] == '.' {
+			p.addPart(raw[o:i])
+			o = i + 1
+		}
+	}
+	return p
+}
+
+// Add part of path between dots, ex: "History" or "History[i]".
+func (p *vpath) addPart(part []byte) {
+	b := bytes.IndexByte(part, '[')
+	if b == -1 {
+		p.seg = append(p.seg, vseg{key: fastconv.B2S(part)})
+		return
+	}
+	if b > 0 {
+		p.seg = append(p.seg, vseg{key: fastconv.B2S(part[:b])})
+	}
+	for b < len(part) && part[b] == '[' {
+		e := exprBracketEnd(part, b)
+		if e == -1 {
+			break
+		}
+		p.addIdx(bytealg.Trim(part[b+1:e], space))
+		b = e + 1
+	}
+	if b < len(part) {
+		p.seg = append(p.seg, vseg{key: fastconv.B2S(part[b:])})
+	}
+}
+
+// Add index segment.
+//
+// Quoted strings and numbers are static keys, all other expressions are paths of variables.
+func (p *vpath) addIdx(expr []byte) {
+	if len(expr) > 1 && bytes.IndexByte(quotes, expr[0]) != -1 && expr[len(expr)-1] == expr[0] {
+		p.seg = append(p.seg, vseg{key: fastconv.B2S(expr[1 : len(expr)-1])})
+		return
+	}
+	if isNumeric(expr) {
+		p.seg = append(p.seg, vseg{key: fastconv.B2S(expr)})
+		return
+	}
+	// Reuse existing index path if possible.
+	var idx *vpath
+	if len(p.seg) < cap(p.seg) {
+		idx = p.seg[:len(p.seg)+1][len(p.seg)].idx
+	}
+	if idx == nil {
+		idx = &vpath{}
+	}
+	p.seg = append(p.seg, vseg{idx: idx.parse(expr)})
+	p.dyn = true
+}
+
+// Check if expression is a non-negative integer.
+func isNumeric(expr []byte) bool {
+	if len(expr) == 0 {
+		return false
+	}
+	for _, c := range expr {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Split path of non-static argument.
+func splitArg(a *arg) {
+	if a != nil && !a.static {
+		a.path = newPath(a.val)
+	}
+}
+
+// Split paths of the arguments list.
+func splitArgs(args []*arg) {
+	for _, a := range args {
+		splitArg(a)
+	}
+}
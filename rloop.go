@@ -17,17 +17,28 @@ const (
 type RangeLoop struct {
 	cntr int
 	stat uint
-	node Node
+	node *Node
 	tpl  *Tpl
 	ctx  *Ctx
 	next *RangeLoop
 	w    io.Writer
+	body loopBody
+}
+
+// Body of the loop, renders on each iteration by one of the ways:
+// * generated function, see GenNode.Loop()
+// * range of instructions of compiled program, see compileLoop()
+// * tree walker over child nodes of the loop node otherwise.
+type loopBody struct {
+	fn GenFn
+	// Offsets of child nodes of the loop in the program and offset of the end of the body.
+	seg []int
 }
 
 // Init new RL.
 func NewRangeLoop(node Node, tpl *Tpl, ctx *Ctx, w io.Writer) *RangeLoop {
 	rl := RangeLoop{
-		node: node,
+		node: &node,
 		tpl:  tpl,
 		ctx:  ctx,
 		w:    w,
@@ -56,17 +67,38 @@ func (rl *RangeLoop) Iterate() inspector.LoopCtl {
 		_, _ = rl.w.Write(rl.node.loopSep)
 	}
 	rl.cntr++
-	var err error
-	for _, ch := range rl.node.child {
-		err = rl.tpl.renderNode(rl.w, ch, rl.ctx)
-		if err == ErrBreakLoop {
-			return inspector.LoopCtlBrk
+	err := rl.body.render(rl.w, rl.tpl, rl.node, rl.ctx)
+	if err == ErrBreakLoop {
+		return inspector.LoopCtlBrk
+	}
+	if err == ErrContLoop {
+		return inspector.LoopCtlCnt
+	}
+	return inspector.LoopCtlNone
+}
+
+// Render the body of the loop node.
+//
+// Errors of child nodes (except of break and continue) don't interrupt the iteration, the next child node renders.
+// Generated function interrupts on any error.
+func (b *loopBody) render(w io.Writer, tpl *Tpl, node *Node, ctx *Ctx) (err error) {
+	if b.fn != nil {
+		return b.fn(w, ctx)
+	}
+	if len(b.seg) > 0 {
+		for i := 1; i < len(b.seg); i++ {
+			if err = tpl.run(w, ctx, b.seg[i-1], b.seg[i]); err == ErrBreakLoop || err == ErrContLoop {
+				return
+			}
 		}
-		if err == ErrContLoop {
-			return inspector.LoopCtlCnt
+		return nil
+	}
+	for i := range node.child {
+		if err = tpl.renderNode(w, &node.child[i], ctx); err == ErrBreakLoop || err == ErrContLoop {
+			return
 		}
 	}
-	return inspector.LoopCtlNone
+	return nil
 }
 
 // Clear all data in the list of RL.
@@ -78,6 +110,8 @@ func (rl *RangeLoop) Reset() {
 		crl.ctx = nil
 		crl.tpl = nil
 		crl.w = nil
+		crl.node = nil
+		crl.body = loopBody{}
 		crl = crl.next
 	}
 }
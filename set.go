@@ -0,0 +1,148 @@
+package dyntpl
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Set is an isolated collection of templates, modifiers and condition helpers.
+//
+// Templates of the set may include and extend only templates of the same set and use only its modifiers and condition
+// helpers. So several libraries may use dyntpl in the same binary without conflicts of IDs and names, and tests may
+// isolate their state. Package level functions (RegisterTpl(), Parse(), Render(), ...) work with the default set.
+type Set struct {
+	// Templates registry: current generation (*tplGen) updated in place by single writes and replaced by batches and
+	// commits, so renders read it without locks. Writers serialize by mutex.
+	mux sync.Mutex
+	gen atomic.Value
+	// Retained generations (from the oldest) and max count of them, see SetTplHistory().
+	hist    []*tplGen
+	histLen int
+	// Registries of modifiers and condition helpers. Lookups happen at parse time only, so plain read-write lock is
+	// enough.
+	rmux sync.RWMutex
+	mod  map[string]ModFn
+	cond map[string]CondFn
+}
+
+// Generation of templates registry.
+//
+// Current generation changes in place by single writes, retained generations are immutable.
+type tplGen struct {
+	set *Set
+	ver uint64
+	tpl sync.Map
+}
+
+const (
+	// Default count of retained generations to keep.
+	tplHistory = 10
+)
+
+var (
+	// Default set, see DefaultSet().
+	defaultSet = newSet()
+)
+
+// NewSet makes new empty set with builtin modifiers and condition helpers.
+func NewSet() *Set {
+	s := newSet()
+	registerBuiltin(s)
+	return s
+}
+
+// DefaultSet returns the set used by package level functions.
+func DefaultSet() *Set {
+	return defaultSet
+}
+
+// Make the set with empty registries.
+func newSet() *Set {
+	s := &Set{
+		mod:     make(map[string]ModFn),
+		cond:    make(map[string]CondFn),
+		histLen: tplHistory,
+	}
+	s.gen.Store(&tplGen{set: s})
+	return s
+}
+
+// Get current generation of the templates registry.
+func (s *Set) cur() *tplGen {
+	return s.gen.Load().(*tplGen)
+}
+
+// Get current snapshot of the templates registry.
+func (s *Set) tpls() map[string]*Tpl {
+	return s.cur().all()
+}
+
+// Get template from the registry.
+func (s *Set) getTpl(id string) *Tpl {
+	return s.cur().get(id)
+}
+
+// Modify current generation of templates registry in place.
+func (s *Set) updTpl(fn func(g *tplGen)) {
+	s.mux.Lock()
+	fn(s.cur())
+	s.mux.Unlock()
+}
+
+// Make new generation with templates of src modified by fn current and retain current one in history.
+//
+// Caller must hold the mutex.
+func (s *Set) publish(src *tplGen, fn func(g *tplGen)) uint64 {
+	cur := s.cur()
+	gen := &tplGen{set: s, ver: cur.ver + 1}
+	src.tpl.Range(func(id, tpl interface{}) bool {
+		gen.tpl.Store(id, tpl)
+		return true
+	})
+	if fn != nil {
+		fn(gen)
+	}
+	s.hist = append(s.hist, cur)
+	s.trimHist()
+	s.gen.Store(gen)
+	return gen.ver
+}
+
+// Remove the oldest generations exceeding the history limit.
+//
+// Caller must hold the mutex.
+func (s *Set) trimHist() {
+	if n := len(s.hist) - s.histLen; n > 0 {
+		for i := 0; i < n; i++ {
+			s.hist[i] = nil
+		}
+		s.hist = append(s.hist[:0], s.hist[n:]...)
+	}
+}
+
+// Get template from the generation the render started with, so all templates of the render belong to the same
+// generation.
+func (t *Tpl) lookup(id string, ctx *Ctx) *Tpl {
+	if g := ctx.gen; g != nil && g.set == t.set {
+		return g.get(id)
+	}
+	return t.set.getTpl(id)
+}
+
+// Get template of the generation.
+func (g *tplGen) get(id string) *Tpl {
+	if tpl, ok := g.tpl.Load(id); ok {
+		return tpl.(*Tpl)
+	}
+	return nil
+}
+
+// Get snapshot of templates of the generation.
+func (g *tplGen) all() map[string]*Tpl {
+	tpls := make(map[string]*Tpl)
+	g.tpl.Range(func(id, tpl interface{}) bool {
+		tpls[id.(string)] = tpl.(*Tpl)
+		return true
+	})
+	return tpls
+}
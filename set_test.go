@@ -0,0 +1,176 @@
+package dyntpl
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	pretest()
+
+	newSet := func(name string, ok bool) *Set {
+		s := NewSet()
+		s.RegisterModFn("testSetName", "", func(_ *Ctx, buf *interface{}, _ interface{}, _ []interface{}) error {
+			*buf = name
+			return nil
+		})
+		s.RegisterCondFn("testSetOk", func(_ *Ctx, _ []interface{}) bool {
+			return ok
+		})
+		for id, body := range map[string]string{
+			"tplSimple": `{% include sub %}`,
+			"sub":       `{%= user.Name|testSetName()|jsonQuote %}{% if testSetOk() %} ok{% endif %}`,
+		} {
+			tree, err := s.Parse([]byte(body), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.RegisterTpl(id, tree)
+		}
+		return s
+	}
+	sets := []struct {
+		set    *Set
+		expect []byte
+	}{
+		{newSet("foo", true), []byte(`"foo" ok`)},
+		{newSet("bar", false), []byte(`"bar"`)},
+		{DefaultSet(), expectSimple},
+	}
+	for _, stage := range sets {
+		ctx := NewCtx()
+		ctx.Set("user", user, &ins)
+		result, err := stage.set.Render("tplSimple", ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		if !bytes.Equal(result, stage.expect) {
+			t.Errorf("set tpl mismatch\nexp: %s\ngot: %s", stage.expect, result)
+		}
+	}
+
+	if GetModFn("testSetName") != nil || GetCondFn("testSetOk") != nil {
+		t.Error("modifier of the set leaks to default set")
+	}
+	if _, err := ParseStrict([]byte(`{%= user.Name|testSetName() %}`), false); err == nil {
+		t.Error("modifier of the set resolves in default set")
+	}
+}
+
+func TestSetConcurrent(t *testing.T) {
+	s := NewSet()
+	tree, _ := s.Parse(tplSimple, false)
+	s.RegisterTpl("tplSimple", tree)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			for j := 0; j < 200; j++ {
+				ctx := AcquireCtx()
+				ctx.Set("user", user, &ins)
+				buf.Reset()
+				if err := s.RenderTo(&buf, "tplSimple", ctx); err != nil {
+					t.Error(err)
+				}
+				if !bytes.Equal(buf.Bytes(), expectSimple) {
+					t.Errorf("concurrent tpl mismatch\nexp: %s\ngot: %s", expectSimple, buf.String())
+				}
+				ReleaseCtx(ctx)
+			}
+		}()
+	}
+	// Registry may be updated at any time.
+	for i := 0; i < 200; i++ {
+		s.RegisterTpl("tplSimple", tree)
+		s.RegisterTpl("tplOther", tree)
+	}
+	wg.Wait()
+}
+
+func BenchmarkSetParallel(b *testing.B) {
+	s := NewSet()
+	tree, _ := s.Parse(tplSimple, false)
+	s.RegisterTpl("tplSimple", tree)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var buf bytes.Buffer
+		for pb.Next() {
+			ctx := AcquireCtx()
+			ctx.Set("user", user, &ins)
+			buf.Reset()
+			if err := s.RenderTo(&buf, "tplSimple", ctx); err != nil {
+				b.Error(err)
+			}
+			ReleaseCtx(ctx)
+		}
+	})
+}
+
+func TestSetFnConcurrent(t *testing.T) {
+	s := NewSet()
+	tree, _ := s.Parse([]byte(`{% if lenGt0(user.Name) %}{%= user.Name|default("none") %}{% endif %}`), false)
+	s.RegisterTpl("tplHlp", tree)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// Plugins may register helpers at runtime.
+		for i := 0; i < 200; i++ {
+			s.RegisterModFn("testSetMod", "", modDefault)
+			s.RegisterCondFn("testSetCond", condLenGt0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if _, err := s.Parse([]byte(`{% if testSetCond(user.Name) %}{%= user.Name|testSetMod() %}{% endif %}`), false); err != nil {
+				t.Error(err)
+			}
+			ctx := NewCtx()
+			ctx.Set("user", user, &ins)
+			result, err := s.Render("tplHlp", ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			if !bytes.Equal(result, []byte(`John`)) {
+				t.Errorf("helper tpl mismatch\nexp: John\ngot: %s", result)
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+func TestSetCondFnLate(t *testing.T) {
+	s := NewSet()
+	tree, _ := s.Parse([]byte(`{% if testLate(user.Name) %}late{% endif %}`), false)
+	s.RegisterTpl("tplLate", tree)
+	var perr *ParseError
+	if errs := s.RevalidateTpl(); !errors.As(errs["tplLate"], &perr) || perr.Code != ParseErrUnknownHlp {
+		t.Errorf("revalidate error mismatch: %v", errs["tplLate"])
+	}
+
+	// Condition helpers resolves at parse time, so late helper requires revalidation.
+	s.RegisterCondFn("testLate", condLenGt0)
+	ctx := NewCtx()
+	ctx.Set("user", user, &ins)
+	if _, err := s.Render("tplLate", ctx); err != ErrCondHlpNotFound {
+		t.Errorf("late helper error mismatch: %v", err)
+	}
+	if errs := s.RevalidateTpl(); errs != nil {
+		t.Error(errs)
+	}
+	result, err := s.Render("tplLate", ctx)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(result, []byte(`late`)) {
+		t.Errorf("late helper tpl mismatch\nexp: late\ngot: %s", result)
+	}
+}
@@ -0,0 +1,127 @@
+// Code generated by dyntpl. DO NOT EDIT.
+
+package testgen
+
+import (
+	"io"
+
+	"github.com/koykov/dyntpl"
+	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/x2bytes"
+)
+
+var (
+	tplCond = dyntpl.MustGenTpl("Cond", []byte(`<h2>Status</h2><p>
+{% if user.Status >= 60 %}Privileged user, your balance: {%= user.Finance.Balance %}.
+{% else %}You don't have enough privileges.{% endif %}</p>
+{% if user.Status < 10 %}
+	anonymous
+{% elseif user.Status < 45 %}
+	logged in
+{% elif lenEq0(user.Id) %}
+	no id
+{% else %}
+	privileged {%= user.Name %}
+{% endif %}
+{% if user.Status >= 60 && (user.Finance.AllowBuy || !lenEq0(user.Id)) %}allowed{% endif %}
+{% if user.Finance.AllowBuy %}unreachable{% endif %}
+`), false)
+	rCondN0     = []byte(`<h2>Status</h2><p>`)
+	rCondN1_0_0 = []byte(`Privileged user, your balance: `)
+	rCondN1_0_2 = []byte(`.`)
+	rCondN1_1_0 = []byte(`You don't have enough privileges.`)
+	rCondN2     = []byte(`</p>`)
+	rCondN3_0_0 = []byte(`anonymous`)
+	rCondN3_1_0 = []byte(`logged in`)
+	nCondN3_2   = tplCond.GenNode(3, 2)
+	rCondN3_2_0 = []byte(`no id`)
+	rCondN3_3_0 = []byte(`privileged `)
+	nCondN4     = tplCond.GenNode(4)
+	rCondN4_0_0 = []byte(`allowed`)
+	nCondN5     = tplCond.GenNode(5)
+	rCondN5_0_0 = []byte(`unreachable`)
+)
+
+// RenderCond renders template Cond to the writer.
+func RenderCond(w io.Writer, ctx *dyntpl.Ctx) error {
+	return tplCond.GenRender(w, ctx, renderCond)
+}
+
+func renderCond(w io.Writer, ctx *dyntpl.Ctx) error {
+	var vUser *testobj.TestObject
+	switch x := ctx.GenVar("user").(type) {
+	case *testobj.TestObject:
+		vUser = x
+	case testobj.TestObject:
+		vUser = &x
+	default:
+		return tplCond.GenFallback(w, ctx)
+	}
+	if _, err := w.Write(rCondN0); err != nil {
+		return err
+	}
+	if vUser != nil && vUser.Status >= 60 {
+		if _, err := w.Write(rCondN1_0_0); err != nil {
+			return err
+		}
+		if vUser != nil && vUser.Finance != nil {
+			ctx.Buf, _ = x2bytes.ToBytesWR(ctx.Buf[:0], &vUser.Finance.Balance)
+			if _, err := w.Write(ctx.Buf); err != nil {
+				return err
+			}
+		} else {
+			return dyntpl.ErrEmptyArg
+		}
+		if _, err := w.Write(rCondN1_0_2); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(rCondN1_1_0); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(rCondN2); err != nil {
+		return err
+	}
+	if vUser != nil && vUser.Status < 10 {
+		if _, err := w.Write(rCondN3_0_0); err != nil {
+			return err
+		}
+	} else if vUser != nil && vUser.Status < 45 {
+		if _, err := w.Write(rCondN3_1_0); err != nil {
+			return err
+		}
+	} else if r, err := nCondN3_2.Cond(ctx); err != nil {
+		return err
+	} else if r {
+		if _, err := w.Write(rCondN3_2_0); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(rCondN3_3_0); err != nil {
+			return err
+		}
+		if vUser != nil {
+			if _, err := w.Write(vUser.Name); err != nil {
+				return err
+			}
+		} else {
+			return dyntpl.ErrEmptyArg
+		}
+	}
+	if r, err := nCondN4.Cond(ctx); err != nil {
+		return err
+	} else if r {
+		if _, err := w.Write(rCondN4_0_0); err != nil {
+			return err
+		}
+	}
+	if r, err := nCondN5.Cond(ctx); err != nil {
+		return err
+	} else if r {
+		if _, err := w.Write(rCondN5_0_0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
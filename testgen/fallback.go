@@ -0,0 +1,164 @@
+// Code generated by dyntpl. DO NOT EDIT.
+
+package testgen
+
+import (
+	"io"
+
+	"github.com/koykov/dyntpl"
+	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/x2bytes"
+)
+
+var (
+	tplFallback = dyntpl.MustGenTpl("Fallback", []byte(`{% ctx status = user.Status %}{% counter n = 0 %}{
+	"status":{%= status %},
+	"flags":[{% for k, v := range user.Flags sep , %}{% counter n++ %}"{%= k %}":{%= v %}{% endfor %}],
+	"n":{%= n %},
+	"history":[{% for _, h := range user.Finance.History sep , %}"{%= h.Comment %}"{% endfor %}],
+	"last":"{%= h.Comment %}",
+	"costs":[{% for j := 0; j < 3; j++ %}{%= user.Finance.History[j].Cost %}{% counter n++ %};{% endfor %}],
+	"n":{%= n %}
+}{% exit %}
+unreachable
+`), false)
+	nFallbackN0    = tplFallback.GenNode(0)
+	nFallbackN1    = tplFallback.GenNode(1)
+	rFallbackN2    = []byte(`{"status":`)
+	nFallbackN3    = tplFallback.GenNode(3)
+	rFallbackN4    = []byte(`,"flags":[`)
+	nFallbackN5_0  = tplFallback.GenNode(5, 0)
+	rFallbackN5_1  = []byte(`"`)
+	nFallbackN5_2  = tplFallback.GenNode(5, 2)
+	rFallbackN5_3  = []byte(`":`)
+	nFallbackN5_4  = tplFallback.GenNode(5, 4)
+	nFallbackN5    = tplFallback.GenNode(5)
+	rFallbackN6    = []byte(`],"n":`)
+	nFallbackN7    = tplFallback.GenNode(7)
+	rFallbackN8    = []byte(`,"history":[`)
+	rFallbackN9_0  = []byte(`"`)
+	rFallbackN9_2  = []byte(`"`)
+	nFallbackN9    = tplFallback.GenNode(9)
+	rFallbackN10   = []byte(`],"last":"`)
+	nFallbackN11   = tplFallback.GenNode(11)
+	rFallbackN12   = []byte(`","costs":[`)
+	nFallbackN13_1 = tplFallback.GenNode(13, 1)
+	rFallbackN13_2 = []byte(`;`)
+	nFallbackN13   = tplFallback.GenNode(13)
+	rFallbackN14   = []byte(`],"n":`)
+	nFallbackN15   = tplFallback.GenNode(15)
+	rFallbackN16   = []byte(`}`)
+)
+
+// RenderFallback renders template Fallback to the writer.
+func RenderFallback(w io.Writer, ctx *dyntpl.Ctx) error {
+	return tplFallback.GenRender(w, ctx, renderFallback)
+}
+
+func renderFallback(w io.Writer, ctx *dyntpl.Ctx) error {
+	var vUser *testobj.TestObject
+	switch x := ctx.GenVar("user").(type) {
+	case *testobj.TestObject:
+		vUser = x
+	case testobj.TestObject:
+		vUser = &x
+	default:
+		return tplFallback.GenFallback(w, ctx)
+	}
+	if err := nFallbackN0.Render(w, ctx); err != nil {
+		return err
+	}
+	if err := nFallbackN1.Render(w, ctx); err != nil {
+		return err
+	}
+	if _, err := w.Write(rFallbackN2); err != nil {
+		return err
+	}
+	if err := nFallbackN3.Render(w, ctx); err != nil {
+		return err
+	}
+	if _, err := w.Write(rFallbackN4); err != nil {
+		return err
+	}
+	if err := nFallbackN5.Loop(w, ctx, func(w io.Writer, ctx *dyntpl.Ctx) error {
+		if err := nFallbackN5_0.Render(w, ctx); err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {
+			return err
+		}
+		_, _ = w.Write(rFallbackN5_1)
+		if err := nFallbackN5_2.Render(w, ctx); err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {
+			return err
+		}
+		_, _ = w.Write(rFallbackN5_3)
+		if err := nFallbackN5_4.Render(w, ctx); err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if _, err := w.Write(rFallbackN6); err != nil {
+		return err
+	}
+	if err := nFallbackN7.Render(w, ctx); err != nil {
+		return err
+	}
+	if _, err := w.Write(rFallbackN8); err != nil {
+		return err
+	}
+	if err := nFallbackN9.Loop(w, ctx, func(w io.Writer, ctx *dyntpl.Ctx) error {
+		var vH *testobj.TestHistory
+		switch x := ctx.GenVar("h").(type) {
+		case *testobj.TestHistory:
+			vH = x
+		case testobj.TestHistory:
+			vH = &x
+		}
+		_, _ = w.Write(rFallbackN9_0)
+		if vH != nil {
+			_, _ = w.Write(vH.Comment)
+		}
+		_, _ = w.Write(rFallbackN9_2)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if _, err := w.Write(rFallbackN10); err != nil {
+		return err
+	}
+	if err := nFallbackN11.Render(w, ctx); err != nil {
+		return err
+	}
+	if _, err := w.Write(rFallbackN12); err != nil {
+		return err
+	}
+	if err := nFallbackN13.Loop(w, ctx, func(w io.Writer, ctx *dyntpl.Ctx) error {
+		var vJ *int64
+		switch x := ctx.GenVar("j").(type) {
+		case *int64:
+			vJ = x
+		case int64:
+			vJ = &x
+		}
+		if vUser != nil && vUser.Finance != nil && vJ != nil && *vJ >= 0 && *vJ < int64(len(vUser.Finance.History)) {
+			ctx.Buf, _ = x2bytes.ToBytesWR(ctx.Buf[:0], &vUser.Finance.History[*vJ].Cost)
+			_, _ = w.Write(ctx.Buf)
+		}
+		if err := nFallbackN13_1.Render(w, ctx); err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {
+			return err
+		}
+		_, _ = w.Write(rFallbackN13_2)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if _, err := w.Write(rFallbackN14); err != nil {
+		return err
+	}
+	if err := nFallbackN15.Render(w, ctx); err != nil {
+		return err
+	}
+	if _, err := w.Write(rFallbackN16); err != nil {
+		return err
+	}
+	return dyntpl.ErrInterrupt
+}
@@ -0,0 +1,152 @@
+package testgen
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/koykov/dyntpl"
+	"github.com/koykov/inspector"
+	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/inspector/testobj_ins"
+)
+
+var (
+	user = &testobj.TestObject{
+		Id:     "115",
+		Name:   []byte("John"),
+		Status: 78,
+		Ustate: 140,
+		Cost:   12.4,
+		Flags: testobj.TestFlag{
+			"export": 17,
+		},
+		Finance: &testobj.TestFinance{
+			Balance:  9000.015,
+			AllowBuy: false,
+			History: []testobj.TestHistory{
+				{
+					152354345634,
+					14.345241,
+					[]byte("pay for domain"),
+				},
+				{
+					153465345246,
+					-3.0000342543,
+					[]byte("got refund"),
+				},
+				{
+					156436535640,
+					2325242534.35324523,
+					[]byte("maintenance"),
+				},
+			},
+		},
+	}
+	ins testobj_ins.TestObjectInspector
+
+	// Data to render: regular object, object with nil pointers and variable of other type that generated code doesn't
+	// expect.
+	data = []struct {
+		name string
+		val  interface{}
+		ins  inspector.Inspector
+	}{
+		{"user", user, &ins},
+		{"empty", &testobj.TestObject{Id: "2", Status: 5}, &ins},
+		{"other", &testobj.TestFinance{Balance: 15}, &testobj_ins.TestFinanceInspector{}},
+	}
+
+	stages = []struct {
+		file string
+		fn   dyntpl.GenFn
+	}{
+		{"print", RenderPrint},
+		{"cond", RenderCond},
+		{"switch", RenderSwitch},
+		{"loop", RenderLoop},
+		{"fallback", RenderFallback},
+		{"mode", RenderMode},
+	}
+)
+
+var (
+	// Template uses modifier registered in init() below, after initialization of package variables.
+	tplLazy = dyntpl.MustGenTpl("Lazy", []byte(`Hello {%= user.Name|testgenLazy() %}`), false)
+	nLazy   = tplLazy.GenNode(1)
+	tplBad  = dyntpl.MustGenTpl("Bad", []byte(`{%= user.Name|testgenUnknown() %}`), false)
+)
+
+func init() {
+	dyntpl.RegisterModFn("testgenLazy", "", func(_ *dyntpl.Ctx, buf *interface{}, _ interface{}, _ []interface{}) error {
+		*buf = "lazy"
+		return nil
+	})
+}
+
+func TestGeneratedLazy(t *testing.T) {
+	fn := func(w io.Writer, ctx *dyntpl.Ctx) error {
+		_, _ = w.Write([]byte("Hello "))
+		return nLazy.Render(w, ctx)
+	}
+	ctx := dyntpl.NewCtx()
+	ctx.Set("user", user, &ins)
+	var buf bytes.Buffer
+	if err := tplLazy.GenRender(&buf, ctx, fn); err != nil {
+		t.Error(err)
+	}
+	if buf.String() != "Hello lazy" {
+		t.Errorf("lazy tpl mismatch\nexp: Hello lazy\ngot: %s", buf.String())
+	}
+
+	var perr *dyntpl.ParseError
+	if err := tplBad.GenRender(&buf, ctx, fn); !errors.As(err, &perr) || perr.Code != dyntpl.ParseErrUnknownMod {
+		t.Errorf("unknown modifier error mismatch: %v", err)
+	}
+}
+
+func TestGenerated(t *testing.T) {
+	for _, stage := range stages {
+		tree, err := dyntpl.ParseFile("testdata/"+stage.file+".tpl", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dyntpl.RegisterTpl(stage.file, tree)
+
+		for _, d := range data {
+			ctx := dyntpl.NewCtx()
+			ctx.Set("user", d.val, d.ins)
+			expect, errE := dyntpl.Render(stage.file, ctx)
+
+			var buf bytes.Buffer
+			ctx.Reset()
+			ctx.Set("user", d.val, d.ins)
+			err = stage.fn(&buf, ctx)
+			if err != errE {
+				t.Errorf("generated %s/%s error mismatch\nexp: %v\ngot: %v", stage.file, d.name, errE, err)
+			}
+			if !bytes.Equal(buf.Bytes(), expect) {
+				t.Errorf("generated %s/%s mismatch\nexp: %s\ngot: %s", stage.file, d.name, string(expect), buf.String())
+			}
+		}
+	}
+}
+
+func BenchmarkGenerated(b *testing.B) {
+	for _, stage := range stages {
+		b.Run(stage.file, func(b *testing.B) {
+			var buf bytes.Buffer
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ctx := dyntpl.AcquireCtx()
+				ctx.Set("user", user, &ins)
+				buf.Reset()
+				if err := stage.fn(&buf, ctx); err != nil {
+					b.Error(err)
+				}
+				dyntpl.ReleaseCtx(ctx)
+			}
+		})
+	}
+}
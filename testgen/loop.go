@@ -0,0 +1,116 @@
+// Code generated by dyntpl. DO NOT EDIT.
+
+package testgen
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/koykov/dyntpl"
+	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/x2bytes"
+)
+
+var (
+	tplLoop = dyntpl.MustGenTpl("Loop", []byte(`{
+	"id":"{%= user.Id %}",
+	"fin_history":[
+		{% for k, item := range user.Finance.History sep , %}
+		{% if item.Cost < 0 %}{% continue %}{% endif %}
+		{%= k %}:{
+			"utime":{%= item.DateUnix %},
+			"cost":{%= item.Cost %},
+			"desc":"{%= item.Comment %}"
+		}
+		{% endfor %}
+	],
+	"last":[
+		{% for i := 2; i > 0; i-- %}
+		{% if i < 2 %}{% break %}{% endif %}
+		"{%= user.Finance.History[i].Comment %}"
+		{% endfor %}
+	]
+}
+`), false)
+	rLoopN0   = []byte(`{"id":"`)
+	rLoopN2   = []byte(`","fin_history":[`)
+	rLoopN3_2 = []byte(`:{"utime":`)
+	rLoopN3_4 = []byte(`,"cost":`)
+	rLoopN3_6 = []byte(`,"desc":"`)
+	rLoopN3_8 = []byte(`"}`)
+	lLoopN3   = []byte(`,`)
+	rLoopN4   = []byte(`],"last":[`)
+	rLoopN5_1 = []byte(`"`)
+	rLoopN5_3 = []byte(`"`)
+	rLoopN6   = []byte(`]}`)
+)
+
+// RenderLoop renders template Loop to the writer.
+func RenderLoop(w io.Writer, ctx *dyntpl.Ctx) error {
+	return tplLoop.GenRender(w, ctx, renderLoop)
+}
+
+func renderLoop(w io.Writer, ctx *dyntpl.Ctx) error {
+	var vUser *testobj.TestObject
+	switch x := ctx.GenVar("user").(type) {
+	case *testobj.TestObject:
+		vUser = x
+	case testobj.TestObject:
+		vUser = &x
+	default:
+		return tplLoop.GenFallback(w, ctx)
+	}
+	if _, err := w.Write(rLoopN0); err != nil {
+		return err
+	}
+	if vUser != nil {
+		ctx.Buf = append(ctx.Buf[:0], vUser.Id...)
+		if _, err := w.Write(ctx.Buf); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rLoopN2); err != nil {
+		return err
+	}
+	if vUser != nil && vUser.Finance != nil {
+		for vK := range vUser.Finance.History {
+			vItem := &vUser.Finance.History[vK]
+			if vK > 0 {
+				_, _ = w.Write(lLoopN3)
+			}
+			if vItem.Cost < 0 {
+				continue
+			}
+			ctx.Buf = strconv.AppendInt(ctx.Buf[:0], int64(vK), 10)
+			_, _ = w.Write(ctx.Buf)
+			_, _ = w.Write(rLoopN3_2)
+			ctx.Buf = strconv.AppendInt(ctx.Buf[:0], vItem.DateUnix, 10)
+			_, _ = w.Write(ctx.Buf)
+			_, _ = w.Write(rLoopN3_4)
+			ctx.Buf, _ = x2bytes.ToBytesWR(ctx.Buf[:0], &vItem.Cost)
+			_, _ = w.Write(ctx.Buf)
+			_, _ = w.Write(rLoopN3_6)
+			_, _ = w.Write(vItem.Comment)
+			_, _ = w.Write(rLoopN3_8)
+		}
+	}
+	if _, err := w.Write(rLoopN4); err != nil {
+		return err
+	}
+	for vI := int64(2); vI > 0; vI-- {
+		if vI < 2 {
+			break
+		}
+		_, _ = w.Write(rLoopN5_1)
+		if vUser != nil && vUser.Finance != nil && vI >= 0 && vI < int64(len(vUser.Finance.History)) {
+			_, _ = w.Write(vUser.Finance.History[vI].Comment)
+		}
+		_, _ = w.Write(rLoopN5_3)
+	}
+	if _, err := w.Write(rLoopN6); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Code generated by dyntpl. DO NOT EDIT.
+
+package testgen
+
+import (
+	"io"
+
+	"github.com/koykov/dyntpl"
+	"github.com/koykov/inspector/testobj"
+)
+
+var (
+	tplMode = dyntpl.MustGenTpl("Mode", []byte(`{"id":"{%= user.Id %}","history":[{% for _, h := range user.Finance.History sep , %}{% jsonquote %}"{%= h.Comment %} \ "{% endjsonquote %}{% endfor %}]}
+`), false)
+	nModeN0   = tplMode.GenNode(0)
+	nModeN2   = tplMode.GenNode(2)
+	nModeN3_0 = tplMode.GenNode(3, 0)
+	nModeN3_1 = tplMode.GenNode(3, 1)
+	nModeN3_3 = tplMode.GenNode(3, 3)
+	nModeN3_4 = tplMode.GenNode(3, 4)
+	nModeN3   = tplMode.GenNode(3)
+	nModeN4   = tplMode.GenNode(4)
+)
+
+// RenderMode renders template Mode to the writer.
+func RenderMode(w io.Writer, ctx *dyntpl.Ctx) error {
+	return tplMode.GenRender(w, ctx, renderMode)
+}
+
+func renderMode(w io.Writer, ctx *dyntpl.Ctx) error {
+	var vUser *testobj.TestObject
+	switch x := ctx.GenVar("user").(type) {
+	case *testobj.TestObject:
+		vUser = x
+	case testobj.TestObject:
+		vUser = &x
+	default:
+		return tplMode.GenFallback(w, ctx)
+	}
+	if err := nModeN0.Render(w, ctx); err != nil {
+		return err
+	}
+	if vUser != nil {
+		ctx.Buf = append(ctx.Buf[:0], vUser.Id...)
+		if _, err := w.Write(ctx.Buf); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if err := nModeN2.Render(w, ctx); err != nil {
+		return err
+	}
+	if err := nModeN3.Loop(w, ctx, func(w io.Writer, ctx *dyntpl.Ctx) error {
+		var vH *testobj.TestHistory
+		switch x := ctx.GenVar("h").(type) {
+		case *testobj.TestHistory:
+			vH = x
+		case testobj.TestHistory:
+			vH = &x
+		}
+		if err := nModeN3_0.Render(w, ctx); err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {
+			return err
+		}
+		if err := nModeN3_1.Render(w, ctx); err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {
+			return err
+		}
+		if vH != nil {
+			_, _ = w.Write(vH.Comment)
+		}
+		if err := nModeN3_3.Render(w, ctx); err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {
+			return err
+		}
+		if err := nModeN3_4.Render(w, ctx); err == dyntpl.ErrBreakLoop || err == dyntpl.ErrContLoop {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := nModeN4.Render(w, ctx); err != nil {
+		return err
+	}
+	return nil
+}
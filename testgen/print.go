@@ -0,0 +1,198 @@
+// Code generated by dyntpl. DO NOT EDIT.
+
+package testgen
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/koykov/dyntpl"
+	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/x2bytes"
+)
+
+var (
+	tplPrint = dyntpl.MustGenTpl("Print", []byte(`{
+	"id":"{%= user.Id %}",
+	"name":"{%= user.Name %}",
+	"status":{%= user.Status %},
+	"ustate":{%= user.Ustate %},
+	"cost":{%= user.Cost %},
+	"balance":{%= user.Finance.Balance %},
+	"allow_buy":{%= user.Finance.AllowBuy %},
+	"first":"{%= user.Finance.History[0].Comment %}",
+	"history":[
+		{% for i := 0; i < 4; i++ %}
+		{%= user.Finance.History[i].Cost %}
+		{% if i > 0 %}<li>{%= user.Finance.History[i].Comment prefix <b> suffix </b> %}</li>{% endif %}
+		{% endfor %}
+	],
+	"flag":{%= user.Flags["export"] %},
+	"default":"{%= user.Name|default("anonymous") %}"
+}
+`), false)
+	rPrintN0        = []byte(`{"id":"`)
+	rPrintN2        = []byte(`","name":"`)
+	rPrintN4        = []byte(`","status":`)
+	rPrintN6        = []byte(`,"ustate":`)
+	rPrintN8        = []byte(`,"cost":`)
+	rPrintN10       = []byte(`,"balance":`)
+	rPrintN12       = []byte(`,"allow_buy":`)
+	rPrintN14       = []byte(`,"first":"`)
+	rPrintN16       = []byte(`","history":[`)
+	rPrintN17_1_0_0 = []byte(`<li>`)
+	pPrintN17_1_0_1 = []byte(`<b>`)
+	sPrintN17_1_0_1 = []byte(`</b>`)
+	rPrintN17_1_0_2 = []byte(`</li>`)
+	rPrintN18       = []byte(`],"flag":`)
+	nPrintN19       = tplPrint.GenNode(19)
+	rPrintN20       = []byte(`,"default":"`)
+	nPrintN21       = tplPrint.GenNode(21)
+	rPrintN22       = []byte(`"}`)
+)
+
+// RenderPrint renders template Print to the writer.
+func RenderPrint(w io.Writer, ctx *dyntpl.Ctx) error {
+	return tplPrint.GenRender(w, ctx, renderPrint)
+}
+
+func renderPrint(w io.Writer, ctx *dyntpl.Ctx) error {
+	var vUser *testobj.TestObject
+	switch x := ctx.GenVar("user").(type) {
+	case *testobj.TestObject:
+		vUser = x
+	case testobj.TestObject:
+		vUser = &x
+	default:
+		return tplPrint.GenFallback(w, ctx)
+	}
+	if _, err := w.Write(rPrintN0); err != nil {
+		return err
+	}
+	if vUser != nil {
+		ctx.Buf = append(ctx.Buf[:0], vUser.Id...)
+		if _, err := w.Write(ctx.Buf); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rPrintN2); err != nil {
+		return err
+	}
+	if vUser != nil {
+		if _, err := w.Write(vUser.Name); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rPrintN4); err != nil {
+		return err
+	}
+	if vUser != nil {
+		ctx.Buf = strconv.AppendInt(ctx.Buf[:0], int64(vUser.Status), 10)
+		if _, err := w.Write(ctx.Buf); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rPrintN6); err != nil {
+		return err
+	}
+	if vUser != nil {
+		ctx.Buf = strconv.AppendUint(ctx.Buf[:0], vUser.Ustate, 10)
+		if _, err := w.Write(ctx.Buf); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rPrintN8); err != nil {
+		return err
+	}
+	if vUser != nil {
+		ctx.Buf, _ = x2bytes.ToBytesWR(ctx.Buf[:0], &vUser.Cost)
+		if _, err := w.Write(ctx.Buf); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rPrintN10); err != nil {
+		return err
+	}
+	if vUser != nil && vUser.Finance != nil {
+		ctx.Buf, _ = x2bytes.ToBytesWR(ctx.Buf[:0], &vUser.Finance.Balance)
+		if _, err := w.Write(ctx.Buf); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rPrintN12); err != nil {
+		return err
+	}
+	if vUser != nil && vUser.Finance != nil {
+		ctx.Buf = strconv.AppendBool(ctx.Buf[:0], vUser.Finance.AllowBuy)
+		if _, err := w.Write(ctx.Buf); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rPrintN14); err != nil {
+		return err
+	}
+	if vUser != nil && vUser.Finance != nil && len(vUser.Finance.History) > 0 {
+		if _, err := w.Write(vUser.Finance.History[0].Comment); err != nil {
+			return err
+		}
+	} else {
+		return dyntpl.ErrEmptyArg
+	}
+	if _, err := w.Write(rPrintN16); err != nil {
+		return err
+	}
+	for vI := int64(0); vI < 4; vI++ {
+		if vUser != nil && vUser.Finance != nil && vI >= 0 && vI < int64(len(vUser.Finance.History)) {
+			ctx.Buf, _ = x2bytes.ToBytesWR(ctx.Buf[:0], &vUser.Finance.History[vI].Cost)
+			_, _ = w.Write(ctx.Buf)
+		}
+		if vI > 0 {
+			if _, err := w.Write(rPrintN17_1_0_0); err != nil {
+				goto skipPrintN17_1
+			}
+			if vUser != nil && vUser.Finance != nil && vI >= 0 && vI < int64(len(vUser.Finance.History)) {
+				_, _ = w.Write(pPrintN17_1_0_1)
+				if _, err := w.Write(vUser.Finance.History[vI].Comment); err != nil {
+					goto skipPrintN17_1
+				}
+				_, _ = w.Write(sPrintN17_1_0_1)
+			} else {
+				goto skipPrintN17_1
+			}
+			if _, err := w.Write(rPrintN17_1_0_2); err != nil {
+				goto skipPrintN17_1
+			}
+		}
+	skipPrintN17_1:
+	}
+	if _, err := w.Write(rPrintN18); err != nil {
+		return err
+	}
+	if err := nPrintN19.Render(w, ctx); err != nil {
+		return err
+	}
+	if _, err := w.Write(rPrintN20); err != nil {
+		return err
+	}
+	if err := nPrintN21.Render(w, ctx); err != nil {
+		return err
+	}
+	if _, err := w.Write(rPrintN22); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Code generated by dyntpl. DO NOT EDIT.
+
+package testgen
+
+import (
+	"io"
+
+	"github.com/koykov/dyntpl"
+	"github.com/koykov/inspector/testobj"
+)
+
+var (
+	tplSwitch = dyntpl.MustGenTpl("Switch", []byte(`{% ctx exactStatus = 78 %}{
+	"permission": "{% switch user.Status %}
+	{% case 10 %}
+		anonymous
+	{% case 45 %}
+		logged in
+	{% case exactStatus %}
+		privileged
+	{% default %}
+		unknown
+{% endswitch %}",
+	"group": "{% switch %}
+	{% case user.Status < 10 %}
+		guest
+	{% case user.Status >= 60 && lenGt0(user.Id) %}
+		admin
+	{% default %}
+		user
+{% endswitch %}"
+}
+`), false)
+	nSwitchN0     = tplSwitch.GenNode(0)
+	rSwitchN1     = []byte(`{"permission": "`)
+	nSwitchN2     = tplSwitch.GenNode(2)
+	rSwitchN2_0_0 = []byte(`anonymous`)
+	rSwitchN2_1_0 = []byte(`logged in`)
+	nSwitchN2_2   = tplSwitch.GenNode(2, 2)
+	rSwitchN2_2_0 = []byte(`privileged`)
+	rSwitchN2_3_0 = []byte(`unknown`)
+	rSwitchN3     = []byte(`","group": "`)
+	nSwitchN4     = tplSwitch.GenNode(4)
+	nSwitchN4_0   = tplSwitch.GenNode(4, 0)
+	rSwitchN4_0_0 = []byte(`guest`)
+	nSwitchN4_1   = tplSwitch.GenNode(4, 1)
+	rSwitchN4_1_0 = []byte(`admin`)
+	rSwitchN4_2_0 = []byte(`user`)
+	rSwitchN5     = []byte(`"}`)
+)
+
+// RenderSwitch renders template Switch to the writer.
+func RenderSwitch(w io.Writer, ctx *dyntpl.Ctx) error {
+	return tplSwitch.GenRender(w, ctx, renderSwitch)
+}
+
+func renderSwitch(w io.Writer, ctx *dyntpl.Ctx) error {
+	var vUser *testobj.TestObject
+	switch x := ctx.GenVar("user").(type) {
+	case *testobj.TestObject:
+		vUser = x
+	case testobj.TestObject:
+		vUser = &x
+	default:
+		return tplSwitch.GenFallback(w, ctx)
+	}
+	if err := nSwitchN0.Render(w, ctx); err != nil {
+		return err
+	}
+	if _, err := w.Write(rSwitchN1); err != nil {
+		return err
+	}
+	if vUser != nil && vUser.Status == 10 {
+		if _, err := w.Write(rSwitchN2_0_0); err != nil {
+			return err
+		}
+	} else if vUser != nil && vUser.Status == 45 {
+		if _, err := w.Write(rSwitchN2_1_0); err != nil {
+			return err
+		}
+	} else if r, err := nSwitchN2_2.Case(ctx, nSwitchN2); err != nil {
+		return err
+	} else if r {
+		if _, err := w.Write(rSwitchN2_2_0); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(rSwitchN2_3_0); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(rSwitchN3); err != nil {
+		return err
+	}
+	if r, err := nSwitchN4_0.Case(ctx, nSwitchN4); err != nil {
+		return err
+	} else if r {
+		if _, err := w.Write(rSwitchN4_0_0); err != nil {
+			return err
+		}
+	} else if r, err := nSwitchN4_1.Case(ctx, nSwitchN4); err != nil {
+		return err
+	} else if r {
+		if _, err := w.Write(rSwitchN4_1_0); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write(rSwitchN4_2_0); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(rSwitchN5); err != nil {
+		return err
+	}
+	return nil
+}
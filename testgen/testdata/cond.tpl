@@ -0,0 +1,14 @@
+<h2>Status</h2><p>
+{% if user.Status >= 60 %}Privileged user, your balance: {%= user.Finance.Balance %}.
+{% else %}You don't have enough privileges.{% endif %}</p>
+{% if user.Status < 10 %}
+	anonymous
+{% elseif user.Status < 45 %}
+	logged in
+{% elif lenEq0(user.Id) %}
+	no id
+{% else %}
+	privileged {%= user.Name %}
+{% endif %}
+{% if user.Status >= 60 && (user.Finance.AllowBuy || !lenEq0(user.Id)) %}allowed{% endif %}
+{% if user.Finance.AllowBuy %}unreachable{% endif %}
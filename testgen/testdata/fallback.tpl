@@ -0,0 +1,10 @@
+{% ctx status = user.Status %}{% counter n = 0 %}{
+	"status":{%= status %},
+	"flags":[{% for k, v := range user.Flags sep , %}{% counter n++ %}"{%= k %}":{%= v %}{% endfor %}],
+	"n":{%= n %},
+	"history":[{% for _, h := range user.Finance.History sep , %}"{%= h.Comment %}"{% endfor %}],
+	"last":"{%= h.Comment %}",
+	"costs":[{% for j := 0; j < 3; j++ %}{%= user.Finance.History[j].Cost %}{% counter n++ %};{% endfor %}],
+	"n":{%= n %}
+}{% exit %}
+unreachable
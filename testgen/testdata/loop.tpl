@@ -0,0 +1,19 @@
+{
+	"id":"{%= user.Id %}",
+	"fin_history":[
+		{% for k, item := range user.Finance.History sep , %}
+		{% if item.Cost < 0 %}{% continue %}{% endif %}
+		{%= k %}:{
+			"utime":{%= item.DateUnix %},
+			"cost":{%= item.Cost %},
+			"desc":"{%= item.Comment %}"
+		}
+		{% endfor %}
+	],
+	"last":[
+		{% for i := 2; i > 0; i-- %}
+		{% if i < 2 %}{% break %}{% endif %}
+		"{%= user.Finance.History[i].Comment %}"
+		{% endfor %}
+	]
+}
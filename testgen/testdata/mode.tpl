@@ -0,0 +1 @@
+{"id":"{%= user.Id %}","history":[{% for _, h := range user.Finance.History sep , %}{% jsonquote %}"{%= h.Comment %} \ "{% endjsonquote %}{% endfor %}]}
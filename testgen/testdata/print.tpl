@@ -0,0 +1,18 @@
+{
+	"id":"{%= user.Id %}",
+	"name":"{%= user.Name %}",
+	"status":{%= user.Status %},
+	"ustate":{%= user.Ustate %},
+	"cost":{%= user.Cost %},
+	"balance":{%= user.Finance.Balance %},
+	"allow_buy":{%= user.Finance.AllowBuy %},
+	"first":"{%= user.Finance.History[0].Comment %}",
+	"history":[
+		{% for i := 0; i < 4; i++ %}
+		{%= user.Finance.History[i].Cost %}
+		{% if i > 0 %}<li>{%= user.Finance.History[i].Comment prefix <b> suffix </b> %}</li>{% endif %}
+		{% endfor %}
+	],
+	"flag":{%= user.Flags["export"] %},
+	"default":"{%= user.Name|default("anonymous") %}"
+}
@@ -0,0 +1,20 @@
+{% ctx exactStatus = 78 %}{
+	"permission": "{% switch user.Status %}
+	{% case 10 %}
+		anonymous
+	{% case 45 %}
+		logged in
+	{% case exactStatus %}
+		privileged
+	{% default %}
+		unknown
+{% endswitch %}",
+	"group": "{% switch %}
+	{% case user.Status < 10 %}
+		guest
+	{% case user.Status >= 60 && lenGt0(user.Id) %}
+		admin
+	{% default %}
+		user
+{% endswitch %}"
+}
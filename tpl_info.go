@@ -0,0 +1,91 @@
+package dyntpl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+)
+
+// TplInfo describes registered template.
+type TplInfo struct {
+	Id string
+	// Time of registration.
+	Registered time.Time
+	// SHA-256 hash of the template source in hex.
+	SrcHash string
+	// Duration of parsing (or loading from binary format) of the template.
+	ParseDur time.Duration
+	// IDs of included templates (including fallback IDs) in order of appearance.
+	Includes []string
+	// ID of the parent template if template extends other template.
+	Extends string
+}
+
+// Get sorted list of IDs of templates registered in the default set.
+func ListTpl() []string {
+	return defaultSet.ListTpl()
+}
+
+// Get info of the template registered in the default set.
+//
+// Returns nil if template isn't registered.
+func GetTplInfo(id string) *TplInfo {
+	return defaultSet.GetTplInfo(id)
+}
+
+// Get sorted list of IDs of templates registered in the set.
+func (s *Set) ListTpl() []string {
+	tpls := s.tpls()
+	ids := make([]string, 0, len(tpls))
+	for id := range tpls {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Get info of the template registered in the set.
+//
+// Returns nil if template isn't registered. Info collects on each call, so don't use it on hot paths.
+func (s *Set) GetTplInfo(id string) *TplInfo {
+	tpl := s.getTpl(id)
+	if tpl == nil {
+		return nil
+	}
+	info := &TplInfo{
+		Id:         tpl.Id,
+		Registered: tpl.reg,
+	}
+	if tree := tpl.tree; tree != nil {
+		h := sha256.Sum256(tree.src)
+		info.SrcHash = hex.EncodeToString(h[:])
+		info.ParseDur = tree.dur
+		info.Includes = collectIncludes(tree.nodes, info.Includes)
+		info.Extends = string(tree.ext)
+	}
+	return info
+}
+
+// Collect unique IDs of included templates.
+func collectIncludes(nodes []Node, ids []string) []string {
+	for i := range nodes {
+		node := &nodes[i]
+		if node.typ == TypeInclude {
+			for _, raw := range node.tpl {
+				id := string(raw)
+				var found bool
+				for _, id1 := range ids {
+					if found = id1 == id; found {
+						break
+					}
+				}
+				if !found {
+					ids = append(ids, id)
+				}
+			}
+		}
+		ids = collectIncludes(node.child, ids)
+	}
+	return ids
+}
@@ -0,0 +1,56 @@
+package dyntpl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTplInfo(t *testing.T) {
+	s := NewSet()
+	src := []byte(`{% extends layout %}{% block body %}{% include row %}{% if user.Id %}{% include card cardFb %}{% endif %}{% include row %}{% endblock %}`)
+	tree, err := s.Parse(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	s.RegisterTpl("page", tree)
+	row, _ := s.Parse([]byte(`row`), false)
+	s.RegisterTpl("row", row)
+
+	if ids := s.ListTpl(); !reflect.DeepEqual(ids, []string{"page", "row"}) {
+		t.Errorf("list of tpl mismatch: %v", ids)
+	}
+
+	info := s.GetTplInfo("page")
+	if info == nil {
+		t.Fatal("tpl info not found")
+	}
+	h := sha256.Sum256(src)
+	if info.Id != "page" || info.SrcHash != hex.EncodeToString(h[:]) || info.Extends != "layout" ||
+		info.Registered.Before(before) || info.ParseDur < 0 {
+		t.Errorf("tpl info mismatch: %+v", info)
+	}
+	if exp := []string{"row", "card", "cardFb"}; !reflect.DeepEqual(info.Includes, exp) {
+		t.Errorf("includes mismatch\nexp: %v\ngot: %v", exp, info.Includes)
+	}
+
+	// Retain current version to keep removed template in it.
+	ver := s.TplVersion()
+	s.CommitTpl()
+	if !s.UnregisterTpl("row") || s.UnregisterTpl("row") {
+		t.Error("unregister tpl result mismatch")
+	}
+	if s.GetTplInfo("row") != nil {
+		t.Error("unregistered tpl is still available")
+	}
+	if ids := s.ListTpl(); !reflect.DeepEqual(ids, []string{"page"}) {
+		t.Errorf("list of tpl mismatch: %v", ids)
+	}
+	// Retained version still contains removed template.
+	if result, _ := s.RenderVersion(ver, "row", NewCtx()); string(result) != "row" {
+		t.Error("removed tpl isn't available in previous version")
+	}
+}
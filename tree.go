@@ -3,19 +3,143 @@ package dyntpl
 import (
 	"bytes"
 	"strconv"
+	"time"
 )
 
 // Tree structure that represents parsed template as list of nodes with childrens.
 type Tree struct {
 	nodes []Node
+	// ID of the parent template, see TypeExtends.
+	ext []byte
+	// Index of blocks to override blocks of parent template.
+	blocks map[string]*Node
+	// Index of macros defined in the template.
+	macros map[string]*Node
+	// Compiled program, see compile().
+	prog []instr
+	// Source of the template and format flag, need to generate Go code, see Generate().
+	src     []byte
+	keepFmt bool
+	// Duration of parsing or loading of the tree, see TplInfo.
+	dur time.Duration
 }
 
 // Representation argument of modifier or helper.
 type arg struct {
 	val    []byte
+	path   *vpath
 	static bool
 }
 
+// Named argument, e.g. macro parameter with default value or include argument.
+type namedArg struct {
+	name []byte
+	val  *arg
+}
+
+// Prepare the tree after parsing.
+//
+// Takes parent template ID from the root extends node, builds indexes of all blocks and macros in the tree, splits
+// paths of variables and compiles the tree to the program.
+func (t *Tree) prepare() {
+	for i := 0; i < len(t.nodes); i++ {
+		if t.nodes[i].typ == TypeExtends && len(t.ext) == 0 {
+			t.ext = t.nodes[i].tpl[0]
+		}
+	}
+	t.index(t.nodes)
+	t.split(t.nodes)
+	t.prog = compile(make([]instr, 0, len(t.nodes)), t.nodes)
+}
+
+// Walk over nodes recursively and split paths of all variables.
+func (t *Tree) split(nodes []Node) {
+	for i := 0; i < len(nodes); i++ {
+		node := &nodes[i]
+		switch node.typ {
+		case TypeTpl:
+			node.rawPath = newPath(node.raw)
+		case TypeCtx:
+			if !node.ctxSrcStatic {
+				node.ctxSrcPath = newPath(node.ctxSrc)
+			}
+		case TypeCounter:
+			node.cntrVarPath = newPath(node.cntrVar)
+		case TypeCond, TypeCondElif:
+			if !node.condStaticL {
+				node.condLPath = newPath(node.condL)
+			}
+			if !node.condStaticR {
+				node.condRPath = newPath(node.condR)
+			}
+			splitArgs(node.condHlpArg)
+			node.condExpr.split()
+		case TypeLoopRange:
+			node.loopSrcPath = newPath(node.loopSrc)
+		case TypeLoopCount:
+			if !node.loopCntStatic {
+				node.loopCntPath = newPath(node.loopCntInit)
+			}
+			if !node.loopLimStatic {
+				node.loopLimPath = newPath(node.loopLim)
+			}
+		case TypeSwitch:
+			node.switchArgPath = newPath(node.switchArg)
+		case TypeCase:
+			if !node.caseStaticL {
+				node.caseLPath = newPath(node.caseL)
+			}
+			if !node.caseStaticR {
+				node.caseRPath = newPath(node.caseR)
+			}
+			splitArgs(node.caseHlpArg)
+			node.caseExpr.split()
+		case TypeMacro:
+			for _, param := range node.macroParam {
+				splitArg(param.val)
+			}
+		case TypeCall:
+			splitArgs(node.macroArg)
+		case TypeInclude:
+			for _, a := range node.incArg {
+				splitArg(a.val)
+			}
+		}
+		for j := range node.mod {
+			splitArgs(node.mod[j].arg)
+		}
+		if len(node.child) > 0 {
+			t.split(node.child)
+		}
+	}
+}
+
+// Walk over nodes recursively and register block and macro nodes in the indexes.
+func (t *Tree) index(nodes []Node) {
+	for i := 0; i < len(nodes); i++ {
+		node := &nodes[i]
+		if node.typ == TypeBlock {
+			if t.blocks == nil {
+				t.blocks = make(map[string]*Node)
+			}
+			if _, ok := t.blocks[string(node.block)]; !ok {
+				t.blocks[string(node.block)] = node
+			}
+		}
+		if node.typ == TypeMacro {
+			if t.macros == nil {
+				t.macros = make(map[string]*Node)
+			}
+			if _, ok := t.macros[string(node.macro)]; !ok {
+				t.macros[string(node.macro)] = node
+			}
+		}
+		if len(node.child) > 0 {
+			t.index(node.child)
+		}
+	}
+}
+
 // Build human readable view of the tree.
 func (t *Tree) HumanReadable() []byte {
 	if len(t.nodes) == 0 {
@@ -31,7 +155,7 @@ func (t *Tree) hrHelper(buf *bytes.Buffer, nodes []Node, indent []byte, depth in
 	for _, node := range nodes {
 		buf.Write(bytes.Repeat(indent, depth))
 		buf.WriteString(node.typ.String())
-		if node.typ != TypeExit && node.typ != TypeBreak && node.typ != TypeContinue {
+		if node.typ != TypeExit && node.typ != TypeBreak && node.typ != TypeContinue && node.typ != TypeSuper {
 			buf.WriteByte(':')
 			buf.WriteByte(' ')
 			buf.Write(node.raw)
@@ -102,6 +226,10 @@ func (t *Tree) hrHelper(buf *bytes.Buffer, nodes []Node, indent []byte, depth in
 				buf.WriteByte(')')
 			}
 		}
+		if node.condExpr != nil {
+			buf.WriteString("expr ")
+			node.condExpr.write(buf)
+		}
 
 		if len(node.loopKey) > 0 {
 			buf.WriteString("key ")
@@ -172,6 +300,10 @@ func (t *Tree) hrHelper(buf *bytes.Buffer, nodes []Node, indent []byte, depth in
 				buf.WriteByte(')')
 			}
 		}
+		if node.caseExpr != nil {
+			buf.WriteString("expr ")
+			node.caseExpr.write(buf)
+		}
 
 		if len(node.tpl) > 0 {
 			for _, tpl := range node.tpl {
@@ -179,6 +311,40 @@ func (t *Tree) hrHelper(buf *bytes.Buffer, nodes []Node, indent []byte, depth in
 				buf.WriteByte(' ')
 			}
 		}
+		if len(node.incArg) > 0 {
+			buf.WriteString("with ")
+			t.hrNamedArgs(buf, node.incArg)
+		}
+		if node.incOnly {
+			if len(node.incArg) > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString("only")
+		}
+
+		if len(node.block) > 0 {
+			buf.Write(node.block)
+		}
+
+		if len(node.macro) > 0 {
+			buf.Write(node.macro)
+			buf.WriteByte('(')
+			t.hrNamedArgs(buf, node.macroParam)
+			for j, a := range node.macroArg {
+				if j > 0 {
+					buf.WriteByte(',')
+					buf.WriteByte(' ')
+				}
+				if a.static {
+					buf.WriteByte('"')
+					buf.Write(a.val)
+					buf.WriteByte('"')
+				} else {
+					buf.Write(a.val)
+				}
+			}
+			buf.WriteByte(')')
+		}
 
 		if len(node.mod) > 0 {
 			buf.WriteString(" mod")
@@ -214,3 +380,24 @@ func (t *Tree) hrHelper(buf *bytes.Buffer, nodes []Node, indent []byte, depth in
 		}
 	}
 }
+
+// Internal human readable helper of named arguments list.
+func (t *Tree) hrNamedArgs(buf *bytes.Buffer, args []*namedArg) {
+	for i, a := range args {
+		if i > 0 {
+			buf.WriteByte(',')
+			buf.WriteByte(' ')
+		}
+		buf.Write(a.name)
+		if a.val != nil {
+			buf.WriteByte('=')
+			if a.val.static {
+				buf.WriteByte('"')
+				buf.Write(a.val.val)
+				buf.WriteByte('"')
+			} else {
+				buf.Write(a.val.val)
+			}
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package dyntpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/koykov/fastconv"
+)
+
+const (
+	// Version of binary format of the tree. Increase it on any change of the format.
+	treeBinVer = 1
+)
+
+var (
+	// Signature of binary tree.
+	treeBinSig = []byte("DTPL")
+)
+
+// Encoder of the tree to binary format.
+type treeEncoder struct {
+	buf []byte
+	tmp [binary.MaxVarintLen64]byte
+}
+
+// Decoder of the tree from binary format.
+type treeDecoder struct {
+	set *Set
+	buf []byte
+	off int
+	err error
+}
+
+// MarshalBinary encodes the tree to compact binary format.
+//
+// Format contains only the result of parsing, so derived data (paths of variables, indexes of blocks and macros,
+// compiled program) rebuilds on load, modifiers resolves by name. Use it to cache parsed templates.
+func (t *Tree) MarshalBinary() ([]byte, error) {
+	e := treeEncoder{buf: make([]byte, 0, len(t.src)*2+16)}
+	e.buf = append(e.buf, treeBinSig...)
+	e.uint(treeBinVer)
+	e.bool(t.keepFmt)
+	e.bytes(t.src)
+	e.nodes(t.nodes)
+	return e.buf, nil
+}
+
+// UnmarshalBinary decodes the tree from binary format produced by MarshalBinary.
+//
+// Modifiers resolves using the default set, they should be registered before loading, otherwise ErrModNotFound
+// returns.
+func (t *Tree) UnmarshalBinary(data []byte) error {
+	return t.unmarshal(defaultSet, data)
+}
+
+// UnmarshalTree decodes the tree from binary format using modifiers of the set.
+//
+// See Tree.UnmarshalBinary().
+func (s *Set) UnmarshalTree(data []byte) (*Tree, error) {
+	tree := &Tree{}
+	if err := tree.unmarshal(s, data); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// Decode the tree resolving modifiers in the set.
+func (t *Tree) unmarshal(set *Set, data []byte) error {
+	start := time.Now()
+	if !bytes.HasPrefix(data, treeBinSig) {
+		return ErrTreeBinary
+	}
+	// Copy data since decoded nodes refers to it.
+	d := treeDecoder{set: set, buf: append([]byte(nil), data...), off: len(treeBinSig)}
+	if d.uint() != treeBinVer {
+		return ErrTreeBinVer
+	}
+	tree := Tree{}
+	tree.keepFmt = d.bool()
+	tree.src = d.bytes()
+	tree.nodes = d.nodes()
+	if d.err == nil && d.off != len(d.buf) {
+		d.err = ErrTreeBinary
+	}
+	if d.err != nil {
+		return d.err
+	}
+	tree.prepare()
+	tree.dur = time.Since(start)
+	*t = tree
+	return nil
+}
+
+// Encode list of nodes.
+func (e *treeEncoder) nodes(nodes []Node) {
+	e.uint(uint64(len(nodes)))
+	for i := range nodes {
+		e.node(&nodes[i])
+	}
+}
+
+// Encode the node.
+func (e *treeEncoder) node(n *Node) {
+	e.int(int64(n.typ))
+	e.bytes(n.raw)
+	e.bytes(n.prefix)
+	e.bytes(n.suffix)
+
+	e.bytes(n.ctxVar)
+	e.bytes(n.ctxSrc)
+	e.bool(n.ctxSrcStatic)
+	e.bytes(n.ctxIns)
+
+	e.bytes(n.cntrVar)
+	e.int(int64(n.cntrInit))
+	e.bool(n.cntrInitF)
+	e.int(int64(n.cntrOp))
+	e.int(int64(n.cntrOpArg))
+
+	e.bytes(n.condL)
+	e.bytes(n.condR)
+	e.bool(n.condStaticL)
+	e.bool(n.condStaticR)
+	e.int(int64(n.condOp))
+	e.bytes(n.condHlp)
+	e.args(n.condHlpArg)
+	e.expr(n.condExpr)
+
+	e.bytes(n.loopKey)
+	e.bytes(n.loopVal)
+	e.bytes(n.loopSrc)
+	e.bytes(n.loopCnt)
+	e.bytes(n.loopCntInit)
+	e.bool(n.loopCntStatic)
+	e.int(int64(n.loopCntOp))
+	e.int(int64(n.loopCondOp))
+	e.bytes(n.loopLim)
+	e.bool(n.loopLimStatic)
+	e.bytes(n.loopSep)
+
+	e.bytes(n.switchArg)
+
+	e.bytes(n.caseL)
+	e.bytes(n.caseR)
+	e.bool(n.caseStaticL)
+	e.bool(n.caseStaticR)
+	e.int(int64(n.caseOp))
+	e.bytes(n.caseHlp)
+	e.args(n.caseHlpArg)
+	e.expr(n.caseExpr)
+
+	e.uint(uint64(len(n.tpl)))
+	for _, tpl := range n.tpl {
+		e.bytes(tpl)
+	}
+	e.namedArgs(n.incArg)
+	e.bool(n.incOnly)
+
+	e.bytes(n.block)
+
+	e.bytes(n.macro)
+	e.namedArgs(n.macroParam)
+	e.args(n.macroArg)
+
+	e.uint(uint64(len(n.mod)))
+	for i := range n.mod {
+		e.bytes(n.mod[i].id)
+		e.args(n.mod[i].arg)
+	}
+
+	e.int(int64(n.srcOff))
+	e.int(int64(n.srcEnd))
+
+	e.nodes(n.child)
+}
+
+// Encode condition expression.
+func (e *treeEncoder) expr(x *condExpr) {
+	if x == nil {
+		e.bool(false)
+		return
+	}
+	e.bool(true)
+	e.int(int64(x.typ))
+	e.bytes(x.l)
+	e.bytes(x.r)
+	e.bool(x.sl)
+	e.bool(x.sr)
+	e.int(int64(x.op))
+	e.bytes(x.hlp)
+	e.args(x.hlpArg)
+	e.expr(x.left)
+	e.expr(x.right)
+}
+
+// Encode list of arguments.
+func (e *treeEncoder) args(args []*arg) {
+	if args == nil {
+		// Keep difference between nil and empty lists.
+		e.int(-1)
+		return
+	}
+	e.int(int64(len(args)))
+	for _, a := range args {
+		e.arg(a)
+	}
+}
+
+// Encode argument.
+func (e *treeEncoder) arg(a *arg) {
+	if a == nil {
+		e.bool(false)
+		return
+	}
+	e.bool(true)
+	e.bytes(a.val)
+	e.bool(a.static)
+}
+
+// Encode list of named arguments.
+func (e *treeEncoder) namedArgs(args []*namedArg) {
+	e.uint(uint64(len(args)))
+	for _, a := range args {
+		e.bytes(a.name)
+		e.arg(a.val)
+	}
+}
+
+func (e *treeEncoder) uint(x uint64) {
+	n := binary.PutUvarint(e.tmp[:], x)
+	e.buf = append(e.buf, e.tmp[:n]...)
+}
+
+func (e *treeEncoder) int(x int64) {
+	n := binary.PutVarint(e.tmp[:], x)
+	e.buf = append(e.buf, e.tmp[:n]...)
+}
+
+func (e *treeEncoder) bool(x bool) {
+	if x {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *treeEncoder) bytes(x []byte) {
+	e.uint(uint64(len(x)))
+	e.buf = append(e.buf, x...)
+}
+
+// Decode list of nodes.
+func (d *treeDecoder) nodes() []Node {
+	n := d.len()
+	if n == 0 {
+		return nil
+	}
+	nodes := make([]Node, n)
+	for i := range nodes {
+		d.node(&nodes[i])
+	}
+	return nodes
+}
+
+// Decode the node.
+func (d *treeDecoder) node(n *Node) {
+	n.typ = Type(d.int())
+	n.raw = d.bytes()
+	n.prefix = d.bytes()
+	n.suffix = d.bytes()
+
+	n.ctxVar = d.bytes()
+	n.ctxSrc = d.bytes()
+	n.ctxSrcStatic = d.bool()
+	n.ctxIns = d.bytes()
+
+	n.cntrVar = d.bytes()
+	n.cntrInit = int(d.int())
+	n.cntrInitF = d.bool()
+	n.cntrOp = Op(d.int())
+	n.cntrOpArg = int(d.int())
+
+	n.condL = d.bytes()
+	n.condR = d.bytes()
+	n.condStaticL = d.bool()
+	n.condStaticR = d.bool()
+	n.condOp = Op(d.int())
+	n.condHlp = d.bytes()
+	n.condHlpArg = d.args()
+	n.condHlpFn = d.hlp(n.condHlp)
+	n.condExpr = d.expr()
+
+	n.loopKey = d.bytes()
+	n.loopVal = d.bytes()
+	n.loopSrc = d.bytes()
+	n.loopCnt = d.bytes()
+	n.loopCntInit = d.bytes()
+	n.loopCntStatic = d.bool()
+	n.loopCntOp = Op(d.int())
+	n.loopCondOp = Op(d.int())
+	n.loopLim = d.bytes()
+	n.loopLimStatic = d.bool()
+	n.loopSep = d.bytes()
+
+	n.switchArg = d.bytes()
+
+	n.caseL = d.bytes()
+	n.caseR = d.bytes()
+	n.caseStaticL = d.bool()
+	n.caseStaticR = d.bool()
+	n.caseOp = Op(d.int())
+	n.caseHlp = d.bytes()
+	n.caseHlpArg = d.args()
+	n.caseHlpFn = d.hlp(n.caseHlp)
+	n.caseExpr = d.expr()
+
+	if c := d.len(); c > 0 {
+		n.tpl = make([][]byte, c)
+		for i := range n.tpl {
+			n.tpl[i] = d.bytes()
+		}
+	}
+	n.incArg = d.namedArgs()
+	n.incOnly = d.bool()
+
+	n.block = d.bytes()
+
+	n.macro = d.bytes()
+	n.macroParam = d.namedArgs()
+	n.macroArg = d.args()
+
+	if c := d.len(); c > 0 {
+		n.mod = make([]mod, c)
+		for i := range n.mod {
+			m := &n.mod[i]
+			m.id = d.bytes()
+			m.arg = d.args()
+			if d.err != nil {
+				return
+			}
+			if m.fn = d.set.GetModFn(fastconv.B2S(m.id)); m.fn == nil {
+				d.fail(fmt.Errorf("%w: %s", ErrModNotFound, m.id))
+				return
+			}
+		}
+	}
+
+	n.srcOff = int(d.int())
+	n.srcEnd = int(d.int())
+
+	n.child = d.nodes()
+}
+
+// Decode condition expression.
+func (d *treeDecoder) expr() *condExpr {
+	if !d.bool() {
+		return nil
+	}
+	x := &condExpr{}
+	x.typ = exprType(d.int())
+	x.l = d.bytes()
+	x.r = d.bytes()
+	x.sl = d.bool()
+	x.sr = d.bool()
+	x.op = Op(d.int())
+	x.hlp = d.bytes()
+	x.hlpArg = d.args()
+	x.hlpFn = d.hlp(x.hlp)
+	x.left = d.expr()
+	x.right = d.expr()
+	return x
+}
+
+// Resolve condition helper by name. Unknown helpers fail at render time like in templates parsed by Parse().
+func (d *treeDecoder) hlp(name []byte) *CondFn {
+	if len(name) == 0 {
+		return nil
+	}
+	return d.set.GetCondFn(fastconv.B2S(name))
+}
+
+// Decode list of arguments.
+func (d *treeDecoder) args() []*arg {
+	c := d.int()
+	if c < 0 || d.err != nil {
+		return nil
+	}
+	if c > int64(len(d.buf)-d.off) {
+		// Each argument takes at least one byte.
+		d.fail(ErrTreeBinary)
+		return nil
+	}
+	args := make([]*arg, c)
+	for i := range args {
+		args[i] = d.arg()
+	}
+	return args
+}
+
+// Decode argument.
+func (d *treeDecoder) arg() *arg {
+	if !d.bool() {
+		return nil
+	}
+	a := &arg{}
+	a.val = d.bytes()
+	a.static = d.bool()
+	return a
+}
+
+// Decode list of named arguments.
+func (d *treeDecoder) namedArgs() []*namedArg {
+	c := d.len()
+	if c == 0 {
+		return nil
+	}
+	args := make([]*namedArg, c)
+	for i := range args {
+		args[i] = &namedArg{name: d.bytes(), val: d.arg()}
+	}
+	return args
+}
+
+// Decode length of the list and check it using the rest of data.
+func (d *treeDecoder) len() int {
+	c := d.uint()
+	if c > uint64(len(d.buf)-d.off) {
+		// Each item takes at least one byte.
+		d.fail(ErrTreeBinary)
+		return 0
+	}
+	return int(c)
+}
+
+func (d *treeDecoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.buf[d.off:])
+	if n <= 0 {
+		d.fail(ErrTreeBinary)
+		return 0
+	}
+	d.off += n
+	return x
+}
+
+func (d *treeDecoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.buf[d.off:])
+	if n <= 0 {
+		d.fail(ErrTreeBinary)
+		return 0
+	}
+	d.off += n
+	return x
+}
+
+func (d *treeDecoder) bool() bool {
+	if d.err != nil {
+		return false
+	}
+	if d.off >= len(d.buf) {
+		d.fail(ErrTreeBinary)
+		return false
+	}
+	d.off++
+	return d.buf[d.off-1] != 0
+}
+
+func (d *treeDecoder) bytes() []byte {
+	c := d.len()
+	if c == 0 || d.err != nil {
+		return nil
+	}
+	x := d.buf[d.off : d.off+c : d.off+c]
+	d.off += c
+	return x
+}
+
+// Register the first error.
+func (d *treeDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
@@ -0,0 +1,108 @@
+package dyntpl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTreeBinary(t *testing.T) {
+	pretest()
+
+	// Loaded trees registers in private set to keep the default one untouched.
+	s := NewSet()
+	for id, tpl := range defaultSet.tpls() {
+		tree := tpl.tree
+		raw, err := tree.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tree1 Tree
+		if err = tree1.UnmarshalBinary(raw); err != nil {
+			t.Fatalf("unmarshal %s failed: %s", id, err)
+		}
+		if !bytes.Equal(tree.HumanReadable(), tree1.HumanReadable()) {
+			t.Errorf("binary tree %s mismatch\nexp: %s\ngot: %s", id, tree.HumanReadable(), tree1.HumanReadable())
+		}
+		if raw1, _ := tree1.MarshalBinary(); !bytes.Equal(raw, raw1) {
+			t.Errorf("binary tree %s isn't stable", id)
+		}
+		s.RegisterTpl(id, &tree1)
+	}
+
+	// Render loaded trees, including ones that refers to other templates.
+	stages := []struct {
+		id     string
+		expect []byte
+	}{
+		{"tplSimple", expectSimple},
+		{"tplCondComplex", expectCondComplex},
+		{"tplSwitchComplex", expectSwitch},
+		{"tplLoopRange", expectLoopRange},
+		{"tplIncArgHost", expectTplIncArg},
+		{"tplExtGrandchild", expectExtGrandchild},
+		{"tplMacro", expectMacro},
+	}
+	for _, stage := range stages {
+		ctx := NewCtx()
+		ctx.Set("user", user, &ins)
+		result, err := s.Render(stage.id, ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		if !bytes.Equal(result, stage.expect) {
+			t.Errorf("binary %s mismatch\nexp: %s\ngot: %s", stage.id, stage.expect, result)
+		}
+	}
+}
+
+func TestTreeBinaryError(t *testing.T) {
+	s := NewSet()
+	s.RegisterModFn("testBinary", "", func(_ *Ctx, buf *interface{}, val interface{}, _ []interface{}) error {
+		*buf = val
+		return nil
+	})
+	tree, _ := s.Parse([]byte(`Hello {%= user.Name|testBinary() %}!`), false)
+	raw, _ := tree.MarshalBinary()
+
+	if _, err := s.UnmarshalTree(raw[:len(raw)-1]); err != ErrTreeBinary {
+		t.Errorf("truncated tree error mismatch: %v", err)
+	}
+	if _, err := s.UnmarshalTree([]byte("{%= x %}")); err != ErrTreeBinary {
+		t.Errorf("signature error mismatch: %v", err)
+	}
+	ver := append([]byte(nil), raw...)
+	ver[len(treeBinSig)] = treeBinVer + 1
+	if _, err := s.UnmarshalTree(ver); err != ErrTreeBinVer {
+		t.Errorf("version error mismatch: %v", err)
+	}
+	if _, err := s.UnmarshalTree(raw); err != nil {
+		t.Error(err)
+	}
+
+	// Modifiers resolves by name on load, so the set without modifier can't load the tree.
+	if _, err := NewSet().UnmarshalTree(raw); !errors.Is(err, ErrModNotFound) {
+		t.Errorf("modifier error mismatch: %v", err)
+	}
+}
+
+func BenchmarkTreeParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(tplCondComplex, false); err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkTreeUnmarshal(b *testing.B) {
+	tree, _ := Parse(tplCondComplex, false)
+	raw, _ := tree.MarshalBinary()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var tree1 Tree
+		if err := tree1.UnmarshalBinary(raw); err != nil {
+			b.Error(err)
+		}
+	}
+}
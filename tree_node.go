@@ -4,56 +4,82 @@ package dyntpl
 // Every piece of the template, beginning from static text and finishing of complex structures (switch, loop, ...)
 // Represents by this type.
 type Node struct {
-	typ    Type
-	raw    []byte
-	prefix []byte
-	suffix []byte
+	typ     Type
+	raw     []byte
+	rawPath *vpath
+	prefix  []byte
+	suffix  []byte
 
 	ctxVar       []byte
 	ctxSrc       []byte
+	ctxSrcPath   *vpath
 	ctxSrcStatic bool
 	ctxIns       []byte
 
-	cntrVar   []byte
-	cntrInit  int
-	cntrInitF bool
-	cntrOp    Op
-	cntrOpArg int
+	cntrVar     []byte
+	cntrVarPath *vpath
+	cntrInit    int
+	cntrInitF   bool
+	cntrOp      Op
+	cntrOpArg   int
 
 	condL       []byte
 	condR       []byte
+	condLPath   *vpath
+	condRPath   *vpath
 	condStaticL bool
 	condStaticR bool
 	condOp      Op
 	condHlp     []byte
 	condHlpArg  []*arg
+	condHlpFn   *CondFn
+	condExpr    *condExpr
 
 	loopKey       []byte
 	loopVal       []byte
 	loopSrc       []byte
+	loopSrcPath   *vpath
 	loopCnt       []byte
 	loopCntInit   []byte
+	loopCntPath   *vpath
 	loopCntStatic bool
 	loopCntOp     Op
 	loopCondOp    Op
 	loopLim       []byte
+	loopLimPath   *vpath
 	loopLimStatic bool
 	loopSep       []byte
 
-	switchArg []byte
+	switchArg     []byte
+	switchArgPath *vpath
 
 	caseL       []byte
 	caseR       []byte
+	caseLPath   *vpath
+	caseRPath   *vpath
 	caseStaticL bool
 	caseStaticR bool
 	caseOp      Op
 	caseHlp     []byte
 	caseHlpArg  []*arg
+	caseHlpFn   *CondFn
+	caseExpr    *condExpr
 
-	tpl [][]byte
+	tpl     [][]byte
+	incArg  []*namedArg
+	incOnly bool
+
+	block []byte
+
+	macro      []byte
+	macroParam []*namedArg
+	macroArg   []*arg
 
 	mod []mod
 
+	// Bounds of the control structure in the template source, see Validate().
+	srcOff, srcEnd int
+
 	child []Node
 }
 
@@ -72,23 +98,34 @@ func addRaw(nodes []Node, raw []byte) []Node {
 	return nodes
 }
 
-// Split nodes by divider node.
-func splitNodes(nodes []Node) [][]Node {
+// Walk over the nodes list and group them by dividers, need to make tree of condition structure.
+//
+// Nodes before the first divider make true branch, else-if dividers starts new branches with own conditions and
+// else divider starts the false branch.
+func rollupCondNodes(nodes []Node) []Node {
 	if len(nodes) == 0 {
 		return nil
 	}
-	split := make([][]Node, 0)
-	var o int
-	for i, node := range nodes {
-		if node.typ == TypeDiv {
-			split = append(split, nodes[o:i])
-			o = i + 1
+	var (
+		r     = make([]Node, 0)
+		group = Node{typ: TypeCondTrue}
+	)
+	for _, node := range nodes {
+		switch node.typ {
+		case TypeCondElif:
+			r = append(r, group)
+			group = node
+		case TypeDiv:
+			r = append(r, group)
+			group = Node{typ: TypeCondFalse}
+		default:
+			group.child = append(group.child, node)
 		}
 	}
-	if o < len(nodes) {
-		split = append(split, nodes[o:])
+	if group.typ != TypeCondFalse || len(group.child) > 0 {
+		r = append(r, group)
 	}
-	return split
+	return r
 }
 
 // Walk over the nodes list and group them by the type, need to make tree of switch structure.
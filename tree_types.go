@@ -30,6 +30,12 @@ const (
 	TypeUrlEnc    Type = 19
 	TypeEndUrlEnc Type = 20
 	TypeInclude   Type = 21
+	TypeCondElif  Type = 22
+	TypeExtends   Type = 23
+	TypeBlock     Type = 24
+	TypeSuper     Type = 25
+	TypeMacro     Type = 26
+	TypeCall      Type = 27
 	TypeExit      Type = 99
 
 	// Must be in sync with inspector.Op type.
@@ -57,6 +63,8 @@ func (typ Type) String() string {
 		return "true"
 	case TypeCondFalse:
 		return "false"
+	case TypeCondElif:
+		return "elif"
 	case TypeLoopRange:
 		return "rloop"
 	case TypeLoopCount:
@@ -79,6 +87,16 @@ func (typ Type) String() string {
 		return "div"
 	case TypeInclude:
 		return "inc"
+	case TypeExtends:
+		return "extends"
+	case TypeBlock:
+		return "block"
+	case TypeSuper:
+		return "super"
+	case TypeMacro:
+		return "macro"
+	case TypeCall:
+		return "call"
 	case TypeExit:
 		return "exit"
 	default:
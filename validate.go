@@ -0,0 +1,319 @@
+package dyntpl
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/koykov/inspector"
+)
+
+// SchemaVar describes variable that will be set to the context before render, see Validate().
+type SchemaVar struct {
+	Name string
+	// Optional sample value and its inspector. Need to check sources of range loops.
+	Val interface{}
+	Ins inspector.Inspector
+}
+
+// Template validator.
+type validator struct {
+	tree   *Tree
+	schema map[string]*SchemaVar
+	// Variables defined so far (schema, ctx and counters), variables of loops and macro params are defined only inside
+	// of their bodies.
+	vars map[string]struct{}
+	// Depth of loops.
+	loop int
+	// Names of undefined variables reported for the current node.
+	undef []string
+	errs  ParseErrors
+}
+
+// Validate walks over the tree and reports semantic problems without rendering.
+//
+// Schema is a list of variables that will be set to the context using Ctx.Set() and similar methods. Validator reports:
+// * variables that are never set neither by schema nor by template itself (ctx, counters, loops, macro params)
+// * range loops with non-iterable source (if sample value and inspector of the source is given in schema)
+// * break and continue outside of loops
+// * comparisons of two static values
+// * unreachable code after exit
+// Problems returns as ParseErrors list, nil means that template is valid.
+func Validate(tree *Tree, schema ...SchemaVar) error {
+	if tree == nil {
+		return nil
+	}
+	v := validator{
+		tree:   tree,
+		schema: make(map[string]*SchemaVar, len(schema)),
+		vars:   make(map[string]struct{}, len(schema)),
+	}
+	for i := range schema {
+		v.schema[schema[i].Name] = &schema[i]
+		v.vars[schema[i].Name] = struct{}{}
+	}
+	v.walk(tree.nodes)
+	if len(v.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		return v.errs[i].Offset < v.errs[j].Offset
+	})
+	return v.errs
+}
+
+// Walk over nodes recursively and check them.
+func (v *validator) walk(nodes []Node) {
+	for i := 0; i < len(nodes); i++ {
+		node := &nodes[i]
+		v.undef = v.undef[:0]
+		switch node.typ {
+		case TypeTpl:
+			if !isStatic(node.raw) {
+				v.checkPath(node, node.rawPath)
+			}
+		case TypeCtx:
+			if !node.ctxSrcStatic {
+				v.checkPath(node, node.ctxSrcPath)
+			}
+			v.define(node.ctxVar)
+		case TypeCounter:
+			if node.cntrInitF {
+				v.define(node.cntrVar)
+			} else {
+				v.checkPath(node, node.cntrVarPath)
+			}
+		case TypeCond, TypeCondElif:
+			v.checkPath(node, node.condLPath)
+			v.checkPath(node, node.condRPath)
+			v.checkArgs(node, node.condHlpArg)
+			v.checkExpr(node, node.condExpr)
+			if node.condExpr == nil && len(node.condHlp) == 0 && node.condStaticL && node.condStaticR {
+				v.report(node, ParseErrSenseless, ErrSenselessCond)
+			}
+		case TypeLoopRange:
+			v.checkPath(node, node.loopSrcPath)
+			v.checkIterable(node)
+			sc := v.openScope(node.loopKey, node.loopVal)
+			v.loop++
+			v.walk(node.child)
+			v.loop--
+			v.closeScope(sc)
+			continue
+		case TypeLoopCount:
+			v.checkPath(node, node.loopCntPath)
+			v.checkPath(node, node.loopLimPath)
+			sc := v.openScope(node.loopCnt)
+			v.loop++
+			v.walk(node.child)
+			v.loop--
+			v.closeScope(sc)
+			continue
+		case TypeSwitch:
+			v.checkPath(node, node.switchArgPath)
+			for j := 0; j < len(node.child); j++ {
+				v.undef = v.undef[:0]
+				v.checkCase(node, &node.child[j])
+				v.walk(node.child[j].child)
+			}
+			continue
+		case TypeBreak, TypeContinue:
+			if v.loop == 0 {
+				v.report(node, ParseErrStray, ErrStrayCtl)
+			}
+		case TypeExit:
+			if reachable(nodes[i+1:]) {
+				v.report(node, ParseErrUnreachable, ErrUnreachable)
+			}
+		case TypeCall:
+			v.checkArgs(node, node.macroArg)
+		case TypeInclude:
+			for _, a := range node.incArg {
+				v.checkArgs(node, []*arg{a.val})
+			}
+		case TypeMacro:
+			names := make([][]byte, 0, len(node.macroParam))
+			for _, param := range node.macroParam {
+				names = append(names, param.name)
+			}
+			sc := v.openScope(names...)
+			v.walk(node.child)
+			v.closeScope(sc)
+			continue
+		}
+		for j := range node.mod {
+			v.checkArgs(node, node.mod[j].arg)
+		}
+		if len(node.child) > 0 {
+			v.walk(node.child)
+		}
+	}
+}
+
+// Check case of the switch node.
+func (v *validator) checkCase(node, ch *Node) {
+	if ch.typ != TypeCase {
+		return
+	}
+	v.checkArgs(ch, ch.caseHlpArg)
+	v.checkExpr(ch, ch.caseExpr)
+	if ch.caseExpr != nil || len(ch.caseHlp) > 0 {
+		return
+	}
+	v.checkPath(ch, ch.caseLPath)
+	if len(node.switchArg) > 0 {
+		// Classic switch uses only left side of the case.
+		return
+	}
+	v.checkPath(ch, ch.caseRPath)
+	if ch.caseStaticL && ch.caseStaticR {
+		v.report(ch, ParseErrSenseless, ErrSenselessCond)
+	}
+}
+
+// Check variables and static comparisons of the condition expression.
+func (v *validator) checkExpr(node *Node, e *condExpr) {
+	if e == nil {
+		return
+	}
+	switch e.typ {
+	case exprCmp:
+		v.checkPath(node, e.lp)
+		v.checkPath(node, e.rp)
+		if e.sl && e.sr {
+			v.report(node, ParseErrSenseless, ErrSenselessCond)
+		}
+	case exprVar:
+		v.checkPath(node, e.lp)
+		if e.sl {
+			v.report(node, ParseErrSenseless, ErrSenselessCond)
+		}
+	case exprHlp:
+		v.checkArgs(node, e.hlpArg)
+	}
+	v.checkExpr(node, e.left)
+	v.checkExpr(node, e.right)
+}
+
+// Check variables of the arguments list.
+func (v *validator) checkArgs(node *Node, args []*arg) {
+	for _, a := range args {
+		if a != nil && !a.static {
+			v.checkPath(node, a.path)
+		}
+	}
+}
+
+// Check if root variable of the path and variables of index expressions are defined.
+func (v *validator) checkPath(node *Node, p *vpath) {
+	if p == nil || len(p.seg) == 0 {
+		return
+	}
+	if root := p.seg[0]; root.idx == nil && len(root.key) > 0 {
+		if _, ok := v.vars[root.key]; !ok {
+			v.reportUndef(node, root.key)
+		}
+	}
+	for i := range p.seg {
+		if p.seg[i].idx != nil {
+			v.checkPath(node, p.seg[i].idx)
+		}
+	}
+}
+
+// Check if source of the range loop is iterable using sample value from the schema.
+func (v *validator) checkIterable(node *Node) {
+	p := node.loopSrcPath
+	if p == nil || p.dyn || len(p.seg) == 0 {
+		return
+	}
+	sv, ok := v.schema[p.seg[0].key]
+	if !ok || sv.Val == nil || sv.Ins == nil {
+		return
+	}
+	path := make([]string, 0, len(p.seg)-1)
+	for _, s := range p.seg[1:] {
+		path = append(path, s.key)
+	}
+	val, err := sv.Ins.Get(sv.Val, path...)
+	if err != nil || val == nil {
+		return
+	}
+	rv := reflect.ValueOf(val)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return
+	}
+	v.report(node, ParseErrNotIterable, ErrNotIterable)
+}
+
+// Register variable name as defined.
+func (v *validator) define(name []byte) {
+	if len(name) > 0 {
+		v.vars[string(name)] = struct{}{}
+	}
+}
+
+// Define variables visible only inside the body of loop or macro.
+//
+// Returns names that weren't defined before, closeScope() drops them after the body.
+func (v *validator) openScope(names ...[]byte) []string {
+	var sc []string
+	for _, name := range names {
+		if len(name) == 0 {
+			continue
+		}
+		if _, ok := v.vars[string(name)]; !ok {
+			v.vars[string(name)] = struct{}{}
+			sc = append(sc, string(name))
+		}
+	}
+	return sc
+}
+
+// Drop variables of the scope.
+func (v *validator) closeScope(sc []string) {
+	for _, name := range sc {
+		delete(v.vars, name)
+	}
+}
+
+// Report undefined variable once per node.
+func (v *validator) reportUndef(node *Node, name string) {
+	for _, n := range v.undef {
+		if n == name {
+			return
+		}
+	}
+	v.undef = append(v.undef, name)
+	v.report(node, ParseErrUndefinedVar, fmt.Errorf("%w: %s", ErrUndefinedVar, name))
+}
+
+// Register problem of the node.
+func (v *validator) report(node *Node, code ParseErrCode, err error) {
+	v.errs = append(v.errs, srcError(v.tree.src, code, err, node.srcOff, node.srcEnd))
+}
+
+// Check if nodes list contains anything that produces output or has side effects.
+func reachable(nodes []Node) bool {
+	for i := range nodes {
+		switch nodes[i].typ {
+		case TypeRaw:
+			if len(bytes.TrimSpace(nodes[i].raw)) > 0 {
+				return true
+			}
+		case TypeMacro:
+			// Macro definition renders nothing.
+		default:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package dyntpl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	src := []byte(`{% ctx limit = 10 %}
+{%= user.Name %}{%= guest.Name %}
+{% for _, h := range user.Finance.History %}{%= h.Comment %}{% if h.Cost > limit %}{% break %}{% endif %}{% endfor %}
+{% for _, c := range user.Status %}{%= c %}{% endfor %}
+{%= h.Comment %}{% macro row(x) %}{%= x %}{% endmacro %}{%= x %}
+{% if 1 == 2 %}{% continue %}{% endif %}
+{% exit %}
+tail`)
+	exp := []struct {
+		code      ParseErrCode
+		line, col int
+	}{
+		{ParseErrUndefinedVar, 2, 17},
+		{ParseErrNotIterable, 4, 1},
+		{ParseErrUndefinedVar, 5, 1},
+		{ParseErrUndefinedVar, 5, 57},
+		{ParseErrSenseless, 6, 1},
+		{ParseErrStray, 6, 16},
+		{ParseErrUnreachable, 7, 1},
+	}
+	tree, err := Parse(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Validate(tree, SchemaVar{Name: "user", Val: user, Ins: &ins})
+	errs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("validation errors expected, got %v", err)
+	}
+	if len(errs) != len(exp) {
+		t.Fatalf("validation errors count mismatch\nexp: %d\ngot: %d\n%s", len(exp), len(errs), errs)
+	}
+	for i, e := range exp {
+		if errs[i].Code != e.code || errs[i].Line != e.line || errs[i].Column != e.col {
+			t.Errorf("validation error #%d mismatch\nexp: %s %d:%d\ngot: %s %d:%d", i, e.code, e.line, e.col,
+				errs[i].Code, errs[i].Line, errs[i].Column)
+		}
+	}
+	if !errors.Is(errs[0], ErrUndefinedVar) {
+		t.Errorf("undefined var error mismatch: %s", errs[0])
+	}
+
+	tree, _ = Parse(tplLoopRange, false)
+	if err = Validate(tree, SchemaVar{Name: "user", Val: user, Ins: &ins}); err != nil {
+		t.Error(err)
+	}
+}
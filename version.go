@@ -0,0 +1,173 @@
+package dyntpl
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// Register list of templates in the default set atomically.
+//
+// See Set.RegisterTplBatch().
+func RegisterTplBatch(trees map[string]*Tree) uint64 {
+	return defaultSet.RegisterTplBatch(trees)
+}
+
+// Retain current generation of templates registry of the default set.
+//
+// See Set.CommitTpl().
+func CommitTpl() uint64 {
+	return defaultSet.CommitTpl()
+}
+
+// Get current version of templates registry of the default set.
+func TplVersion() uint64 {
+	return defaultSet.TplVersion()
+}
+
+// Get available versions of templates registry of the default set.
+func TplVersions() []uint64 {
+	return defaultSet.TplVersions()
+}
+
+// Rollback templates registry of the default set to the given version.
+//
+// See Set.RollbackTpl().
+func RollbackTpl(ver uint64) (uint64, error) {
+	return defaultSet.RollbackTpl(ver)
+}
+
+// Set count of retained versions of templates registry of the default set to keep.
+func SetTplHistory(n int) {
+	defaultSet.SetTplHistory(n)
+}
+
+// Render template of the given version of templates registry of the default set.
+//
+// See RenderVersionTo().
+func RenderVersion(ver uint64, id string, ctx *Ctx) ([]byte, error) {
+	return defaultSet.RenderVersion(ver, id, ctx)
+}
+
+// Render template of the given version of templates registry of the default set to given writer object.
+//
+// See Set.RenderVersionTo().
+func RenderVersionTo(w io.Writer, ver uint64, id string, ctx *Ctx) error {
+	return defaultSet.RenderVersionTo(w, ver, id, ctx)
+}
+
+// Register list of templates in the set atomically.
+//
+// Batch registration retains current generation of the registry in history and makes new generation with new version
+// for all templates, so renders see either all new templates or none of them. It is useful when layout and its
+// includes change together. Returns version of new generation.
+func (s *Set) RegisterTplBatch(trees map[string]*Tree) uint64 {
+	if len(trees) == 0 {
+		return s.TplVersion()
+	}
+	now := time.Now()
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.publish(s.cur(), func(g *tplGen) {
+		for id, tree := range trees {
+			g.tpl.Store(id, &Tpl{Id: id, tree: tree, set: s, reg: now})
+		}
+	})
+}
+
+// Retain current generation of templates registry of the set in history.
+//
+// Single writes (RegisterTpl(), UnregisterTpl(), RevalidateTpl()) change current generation in place and don't make
+// new versions, so commit the registry after them to get version to rollback to. Returns version of new generation.
+func (s *Set) CommitTpl() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.publish(s.cur(), nil)
+}
+
+// Get current version of templates registry of the set.
+func (s *Set) TplVersion() uint64 {
+	return s.cur().ver
+}
+
+// Get available versions of templates registry of the set, from the oldest to current.
+func (s *Set) TplVersions() []uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	vers := make([]uint64, 0, len(s.hist)+1)
+	for _, gen := range s.hist {
+		vers = append(vers, gen.ver)
+	}
+	return append(vers, s.cur().ver)
+}
+
+// Rollback templates registry of the set to the given version.
+//
+// Templates of that version become current as a new generation, so versions always grow and retained version always
+// means the same templates. Returns version of new generation or ErrTplNoVer if version isn't retained in history.
+func (s *Set) RollbackTpl(ver uint64) (uint64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.cur().ver == ver {
+		return ver, nil
+	}
+	for _, gen := range s.hist {
+		if gen.ver == ver {
+			// Retained generations are immutable, so templates may be shared.
+			return s.publish(gen, nil), nil
+		}
+	}
+	return 0, ErrTplNoVer
+}
+
+// Set count of retained versions of templates registry of the set to keep, 10 by default.
+//
+// Only batch registrations, commits and rollbacks retain versions, see CommitTpl().
+func (s *Set) SetTplHistory(n int) {
+	if n < 0 {
+		n = 0
+	}
+	s.mux.Lock()
+	s.histLen = n
+	s.trimHist()
+	s.mux.Unlock()
+}
+
+// Render template of the given version of templates registry of the set.
+//
+// See RenderVersionTo().
+func (s *Set) RenderVersion(ver uint64, id string, ctx *Ctx) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := s.RenderVersionTo(&buf, ver, id, ctx)
+	return buf.Bytes(), err
+}
+
+// Render template of the given version of templates registry of the set to given writer object.
+//
+// Included and parent templates take from the same version, so previous versions may be rendered for comparison.
+func (s *Set) RenderVersionTo(w io.Writer, ver uint64, id string, ctx *Ctx) error {
+	gen := s.getGen(ver)
+	if gen == nil {
+		return ErrTplNoVer
+	}
+	tpl := gen.get(id)
+	if tpl == nil {
+		return ErrTplNotFound
+	}
+	return renderGen(w, gen, tpl, ctx)
+}
+
+// Get generation of templates registry by version.
+func (s *Set) getGen(ver uint64) *tplGen {
+	if gen := s.cur(); gen.ver == ver {
+		return gen
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, gen := range s.hist {
+		if gen.ver == ver {
+			return gen
+		}
+	}
+	return nil
+}
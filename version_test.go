@@ -0,0 +1,123 @@
+package dyntpl
+
+import "testing"
+
+func TestTplVersion(t *testing.T) {
+	s := NewSet()
+	s.SetTplHistory(2)
+	batch := func(layout, row string) uint64 {
+		trees := make(map[string]*Tree, 2)
+		for id, body := range map[string]string{"layout": layout, "row": row} {
+			tree, err := s.Parse([]byte(body), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trees[id] = tree
+		}
+		return s.RegisterTplBatch(trees)
+	}
+	render := func(ver uint64, expect string) {
+		ctx := NewCtx()
+		ctx.Set("user", user, &ins)
+		result, err := s.RenderVersion(ver, "layout", ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(result) != expect {
+			t.Errorf("version %d tpl mismatch\nexp: %s\ngot: %s", ver, expect, result)
+		}
+	}
+
+	v1 := batch(`<ul>{% include row %}</ul>`, `<li>{%= user.Name %}</li>`)
+	v2 := batch(`<div>{% include row %}</div>`, `<p>{%= user.Name %}</p>`)
+	if v1 != 1 || v2 != 2 || s.TplVersion() != v2 {
+		t.Errorf("versions mismatch: %d %d %d", v1, v2, s.TplVersion())
+	}
+	render(v2, `<div><p>John</p></div>`)
+	// Previous version renders with its own includes.
+	render(v1, `<ul><li>John</li></ul>`)
+
+	v3, err := s.RollbackTpl(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v3 != 3 {
+		t.Errorf("rollback version mismatch: %d", v3)
+	}
+	render(v3, `<ul><li>John</li></ul>`)
+	render(v2, `<div><p>John</p></div>`)
+
+	// Only two previous versions are kept.
+	tree, _ := s.Parse([]byte(`<b>{%= user.Name %}</b>`), false)
+	s.RegisterTpl("row", tree)
+	if v4 := s.CommitTpl(); v4 != 4 {
+		t.Errorf("commit version mismatch: %d", v4)
+	}
+	ctx := NewCtx()
+	ctx.Set("user", user, &ins)
+	if result, _ := s.Render("layout", ctx); string(result) != `<ul><b>John</b></ul>` {
+		t.Errorf("current tpl mismatch: %s", result)
+	}
+	if vers := s.TplVersions(); len(vers) != 3 || vers[0] != 2 || vers[2] != 4 {
+		t.Errorf("available versions mismatch: %v", vers)
+	}
+	if _, err = s.RollbackTpl(v1); err != ErrTplNoVer {
+		t.Errorf("rollback error mismatch: %v", err)
+	}
+	if _, err = s.RenderVersion(v1, "layout", NewCtx()); err != ErrTplNoVer {
+		t.Errorf("render version error mismatch: %v", err)
+	}
+}
+
+func TestTplVersionSingle(t *testing.T) {
+	s := NewSet()
+	s.SetTplHistory(2)
+	parse := func(body string) *Tree {
+		tree, err := s.Parse([]byte(body), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tree
+	}
+	render := func(ver uint64, expect string) {
+		ctx := NewCtx()
+		ctx.Set("user", user, &ins)
+		result, err := s.RenderVersion(ver, "layout", ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(result) != expect {
+			t.Errorf("version %d tpl mismatch\nexp: %s\ngot: %s", ver, expect, result)
+		}
+	}
+
+	v1 := s.RegisterTplBatch(map[string]*Tree{
+		"layout": parse(`<ul>{% include row %}</ul>`),
+		"row":    parse(`<li>{%= user.Name %}</li>`),
+	})
+	// Single writes change current generation and don't evict retained ones.
+	for i := 0; i < 5; i++ {
+		s.RegisterTpl("extra", parse(`<i>{%= user.Name %}</i>`))
+	}
+	if s.TplVersion() != v1 {
+		t.Errorf("single write changed version: %d", s.TplVersion())
+	}
+	v2 := s.RegisterTplBatch(map[string]*Tree{"row": parse(`<p>{%= user.Name %}</p>`)})
+	s.RegisterTpl("row", parse(`<b>{%= user.Name %}</b>`))
+	s.UnregisterTpl("extra")
+	if vers := s.TplVersions(); len(vers) != 3 || vers[0] != 0 || vers[2] != v2 {
+		t.Errorf("available versions mismatch: %v", vers)
+	}
+	render(v2, `<ul><b>John</b></ul>`)
+
+	v3, err := s.RollbackTpl(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	render(v3, `<ul><li>John</li></ul>`)
+	if s.getTpl("extra") == nil {
+		t.Error("rollback lost template registered by single write")
+	}
+	// Retained generation keeps single writes made before the next batch.
+	render(v2, `<ul><b>John</b></ul>`)
+}
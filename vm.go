@@ -0,0 +1,260 @@
+package dyntpl
+
+import "io"
+
+// Operation code of the program instruction.
+type opcode uint8
+
+const (
+	// Write raw text.
+	opRaw opcode = iota
+	// Print variable with modifiers.
+	opPrint
+	// Evaluate condition and jump if it's false.
+	opJumpF
+	// Evaluate case of the switch and jump if it's false.
+	opCase
+	// Unconditional jump.
+	opJump
+	// Execute the loop, body of the loop follows the instruction.
+	opLoop
+	// Set context variable.
+	opCtx
+	// Set counter variable.
+	opCntr
+	// Render included template.
+	opInclude
+	// Switch escape mode of raw text.
+	opMode
+	// Break the loop.
+	opBreak
+	// Go to next iteration of the loop.
+	opCont
+	// Interrupt template evaluation.
+	opExit
+	// Render node using tree walker (blocks and macro calls).
+	opNode
+)
+
+// Instruction of compiled program.
+type instr struct {
+	op   opcode
+	node *Node
+	// Switch node of the case.
+	sw *Node
+	// Target instruction of the jump.
+	jmp int
+	// Offsets of child nodes of the loop body, see loopBody.
+	seg []int
+}
+
+// Compile list of nodes to the flat list of instructions.
+//
+// Conditions and switches lowers to conditional and unconditional jumps, bodies of loops compiles inline, so the
+// program executes without recursion (except of loops driven by inspectors). Blocks and macro calls are rendered by the
+// tree walker, see Tpl.renderNode().
+func compile(prog []instr, nodes []Node) []instr {
+	for i := 0; i < len(nodes); i++ {
+		node := &nodes[i]
+		switch node.typ {
+		case TypeRaw:
+			prog = append(prog, instr{op: opRaw, node: node})
+		case TypeTpl:
+			prog = append(prog, instr{op: opPrint, node: node})
+		case TypeCond:
+			prog = compileCond(prog, node)
+		case TypeSwitch:
+			prog = compileSwitch(prog, node)
+		case TypeLoopRange, TypeLoopCount:
+			prog = compileLoop(prog, node)
+		case TypeCtx:
+			prog = append(prog, instr{op: opCtx, node: node})
+		case TypeCounter:
+			prog = append(prog, instr{op: opCntr, node: node})
+		case TypeInclude:
+			prog = append(prog, instr{op: opInclude, node: node})
+		case TypeJsonQ, TypeEndJsonQ, TypeHtmlE, TypeEndHtmlE, TypeUrlEnc, TypeEndUrlEnc:
+			prog = append(prog, instr{op: opMode, node: node})
+		case TypeBreak:
+			prog = append(prog, instr{op: opBreak, node: node})
+		case TypeContinue:
+			prog = append(prog, instr{op: opCont, node: node})
+		case TypeExit:
+			prog = append(prog, instr{op: opExit, node: node})
+		case TypeExtends, TypeMacro:
+			// Definitions don't render anything.
+		default:
+			prog = append(prog, instr{op: opNode, node: node})
+		}
+	}
+	return prog
+}
+
+// Compile condition node with all its branches.
+//
+// Example of condition with else-if branch:
+// 0: jumpf A -> 3
+// 1: ... true branch
+// 2: jump -> 7
+// 3: jumpf B -> 6
+// 4: ... else-if branch
+// 5: jump -> 7
+// 6: ... else branch
+// 7: ...
+func compileCond(prog []instr, node *Node) []instr {
+	if len(node.child) == 0 {
+		// Condition must be evaluated anyway to catch errors.
+		return append(prog, instr{op: opJumpF, node: node, jmp: len(prog) + 1})
+	}
+	var ends []int
+	for i := 0; i < len(node.child); i++ {
+		ch := &node.child[i]
+		if ch.typ == TypeCondFalse {
+			prog = compile(prog, ch.child)
+			break
+		}
+		cn := node
+		if ch.typ == TypeCondElif {
+			cn = ch
+		}
+		j := len(prog)
+		prog = append(prog, instr{op: opJumpF, node: cn})
+		prog = compile(prog, ch.child)
+		ends = append(ends, len(prog))
+		prog = append(prog, instr{op: opJump})
+		prog[j].jmp = len(prog)
+	}
+	for _, e := range ends {
+		prog[e].jmp = len(prog)
+	}
+	return prog
+}
+
+// Compile switch node with all its cases.
+//
+// Cases are checked in order of appearance, default case (wherever it placed) follows them:
+// 0: case A -> 3
+// 1: ... case A
+// 2: jump -> 7
+// 3: case B -> 6
+// 4: ... case B
+// 5: jump -> 7
+// 6: ... default case
+// 7: ...
+func compileSwitch(prog []instr, node *Node) []instr {
+	var ends []int
+	for i := 0; i < len(node.child); i++ {
+		ch := &node.child[i]
+		if ch.typ != TypeCase {
+			continue
+		}
+		j := len(prog)
+		prog = append(prog, instr{op: opCase, node: ch, sw: node})
+		prog = compile(prog, ch.child)
+		ends = append(ends, len(prog))
+		prog = append(prog, instr{op: opJump})
+		prog[j].jmp = len(prog)
+	}
+	for i := 0; i < len(node.child); i++ {
+		if ch := &node.child[i]; ch.typ == TypeDefault {
+			prog = compile(prog, ch.child)
+			break
+		}
+	}
+	for _, e := range ends {
+		prog[e].jmp = len(prog)
+	}
+	return prog
+}
+
+// Compile loop node.
+//
+// Body of the loop follows the loop instruction. The loop executes the body on each iteration using offsets of child
+// nodes (errors of one child node don't prevent rendering of the next one, like tree walker does) and then jumps over
+// the body:
+// 0: loop -> 4, body 1, 3, 4
+// 1: ... child A
+// 2: ... child A
+// 3: ... child B
+// 4: ...
+func compileLoop(prog []instr, node *Node) []instr {
+	j := len(prog)
+	prog = append(prog, instr{op: opLoop, node: node})
+	seg := make([]int, 0, len(node.child)+1)
+	for i := 0; i < len(node.child); i++ {
+		seg = append(seg, len(prog))
+		prog = compile(prog, node.child[i:i+1])
+	}
+	seg = append(seg, len(prog))
+	prog[j].jmp, prog[j].seg = len(prog), seg
+	return prog
+}
+
+// Execute compiled program of the template.
+func (t *Tpl) exec(w io.Writer, ctx *Ctx) error {
+	if t.tree.prog == nil {
+		// Tree wasn't compiled, walk over it.
+		return t.renderNodes(w, t.tree.nodes, ctx)
+	}
+	return t.run(w, ctx, 0, len(t.tree.prog))
+}
+
+// Execute instructions of compiled program in range [pc, end).
+func (t *Tpl) run(w io.Writer, ctx *Ctx, pc, end int) (err error) {
+	prog := t.tree.prog
+	for pc < end {
+		in := &prog[pc]
+		pc++
+		switch in.op {
+		case opRaw:
+			if ctx.chJQ || ctx.chHE || ctx.chUE {
+				// Raw text must be escaped.
+				err = t.renderRaw(w, in.node, ctx)
+			} else {
+				_, err = w.Write(in.node.raw)
+			}
+		case opPrint:
+			err = t.renderPrint(w, in.node, ctx)
+		case opJumpF:
+			var r bool
+			if r, err = t.evalCond(in.node, ctx); !r {
+				pc = in.jmp
+			}
+		case opCase:
+			var r bool
+			if r, err = t.evalCase(in.sw, in.node, ctx); !r {
+				pc = in.jmp
+			}
+		case opJump:
+			pc = in.jmp
+		case opLoop:
+			if in.node.typ == TypeLoopCount {
+				ctx.cloop(in.node, t, w, loopBody{seg: in.seg})
+			} else {
+				ctx.rloop(in.node.loopSrcPath, in.node, t, w, loopBody{seg: in.seg})
+			}
+			err = ctx.Err
+			pc = in.jmp
+		case opCtx:
+			err = t.renderCtx(in.node, ctx)
+		case opCntr:
+			err = t.renderCntr(in.node, ctx)
+		case opInclude:
+			err = t.renderInclude(w, in.node, ctx)
+		case opMode:
+			ctx.setMode(in.node.typ)
+		case opBreak:
+			err = ErrBreakLoop
+		case opCont:
+			err = ErrContLoop
+		case opExit:
+			err = ErrInterrupt
+		default:
+			err = t.renderNode(w, in.node, ctx)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
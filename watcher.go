@@ -0,0 +1,201 @@
+package dyntpl
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+const (
+	// Default interval of polling the directory.
+	watchInterval = time.Second
+)
+
+// Watcher reloads templates of the directory on the fly.
+//
+// It polls the directory (see LoadDir() for pattern and IDs details) and re-parses new and modified files. Template
+// replaces in the registry only if parsing succeeds, so broken files don't affect rendering. Removed files keep their
+// last templates registered.
+type Watcher struct {
+	// Callback of successfully (re)loaded template.
+	OnReload func(id, file string)
+	// Callback of failed reading or parsing of the file.
+	OnError func(file string, err error)
+
+	set      *Set
+	dir      string
+	fsys     fs.FS
+	pattern  string
+	keepFmt  bool
+	interval time.Duration
+
+	mux   sync.Mutex
+	files map[string]watchStat
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// State of watched file.
+type watchStat struct {
+	mod  time.Time
+	size int64
+}
+
+// Result of reloading of the file: ID of reloaded template or error.
+type watchEvent struct {
+	id, file string
+	err      error
+}
+
+// NewWatcher makes new watcher of the directory, templates registers in the default set.
+//
+// Interval <= 0 means default interval (1 second). Set callbacks before calling Start().
+func NewWatcher(dir, pattern string, keepFmt bool, interval time.Duration) *Watcher {
+	return defaultSet.NewWatcher(dir, pattern, keepFmt, interval)
+}
+
+// NewWatcher makes new watcher of the directory that registers templates in the set.
+//
+// See NewWatcher().
+func (s *Set) NewWatcher(dir, pattern string, keepFmt bool, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = watchInterval
+	}
+	return &Watcher{
+		set:      s,
+		dir:      dir,
+		fsys:     os.DirFS(dir),
+		pattern:  pattern,
+		keepFmt:  keepFmt,
+		interval: interval,
+		files:    make(map[string]watchStat),
+	}
+}
+
+// Start loads all templates and begins to poll the directory in background.
+//
+// Returns error if pattern is malformed or directory couldn't be read, problems with files reports to OnError callback.
+func (w *Watcher) Start() error {
+	if _, err := path.Match(w.pattern, ""); err != nil {
+		return err
+	}
+	if err := w.Check(); err != nil {
+		return err
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.stop != nil {
+		return nil
+	}
+	w.stop, w.done = make(chan struct{}), make(chan struct{})
+	go w.poll(w.stop, w.done)
+	return nil
+}
+
+// Stop polling and wait for current check finished.
+//
+// Stop must not be called from callbacks: they are called by polling goroutine, so Stop would wait for itself.
+func (w *Watcher) Stop() {
+	w.mux.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mux.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Check the directory immediately and reload changed templates.
+//
+// Callbacks are called after the check finished, so they may call Check() again. Returns error only if directory
+// couldn't be read.
+func (w *Watcher) Check() error {
+	events, err := w.check()
+	for _, e := range events {
+		if e.err != nil {
+			w.fail(e.file, e.err)
+		} else if w.OnReload != nil {
+			w.OnReload(e.id, e.file)
+		}
+	}
+	return err
+}
+
+// Reload changed templates and collect events for callbacks.
+func (w *Watcher) check() ([]watchEvent, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	var (
+		seen   = make(map[string]struct{}, len(w.files))
+		trees  = make(map[string]*Tree)
+		events []watchEvent
+	)
+	err := fs.WalkDir(w.fsys, ".", func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if file == "." {
+				return err
+			}
+			events = append(events, watchEvent{file: file, err: err})
+			return nil
+		}
+		if d.IsDir() || !loadMatch(w.pattern, file) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			events = append(events, watchEvent{file: file, err: err})
+			return nil
+		}
+		seen[file] = struct{}{}
+		stat := watchStat{mod: info.ModTime(), size: info.Size()}
+		if old, ok := w.files[file]; ok && old.mod.Equal(stat.mod) && old.size == stat.size {
+			return nil
+		}
+		// Remember state even if file is broken to report it once.
+		w.files[file] = stat
+		id, tree, err := w.set.loadFile(w.fsys, file, w.keepFmt)
+		if err != nil {
+			events = append(events, watchEvent{file: file, err: err})
+			return nil
+		}
+		trees[id] = tree
+		events = append(events, watchEvent{id: id, file: file})
+		return nil
+	})
+	// Replace all changed templates at once.
+	w.set.RegisterTplBatch(trees)
+	for file := range w.files {
+		if _, ok := seen[file]; !ok {
+			delete(w.files, file)
+		}
+	}
+	return events, err
+}
+
+// Poll the directory until stop signal.
+func (w *Watcher) poll(stop, done chan struct{}) {
+	defer close(done)
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if err := w.Check(); err != nil {
+				w.fail(w.dir, err)
+			}
+		}
+	}
+}
+
+// Report the error to callback.
+func (w *Watcher) fail(file string, err error) {
+	if w.OnError != nil {
+		w.OnError(file, err)
+	}
+}
@@ -0,0 +1,159 @@
+package dyntpl
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dyntpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "watch.tpl")
+	mtime := time.Now().Add(-time.Hour)
+	write := func(body string) {
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Guarantee changing of modification time on file systems with low precision.
+		mtime = mtime.Add(time.Second)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	render := func(expect string) {
+		ctx := NewCtx()
+		ctx.Set("user", user, &ins)
+		result, err := Render("watch", ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(result) != expect {
+			t.Errorf("watched tpl mismatch\nexp: %s\ngot: %s", expect, result)
+		}
+	}
+
+	var reloads, fails int
+	var lastErr error
+	w := NewWatcher(dir, "*.tpl", false, time.Hour)
+	w.OnReload = func(id, _ string) {
+		if id == "watch" {
+			reloads++
+		}
+	}
+	w.OnError = func(_ string, err error) {
+		fails++
+		lastErr = err
+	}
+
+	write(`Hello {%= user.Name %}`)
+	if err = w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	render("Hello John")
+
+	// Broken template keeps previous version registered.
+	write(`Hello {% if a && (b %}x{% endif %}`)
+	_ = w.Check()
+	_ = w.Check()
+	if fails != 1 || !errors.Is(lastErr, ErrBadCond) {
+		t.Errorf("watcher error mismatch: %d %v", fails, lastErr)
+	}
+	render("Hello John")
+
+	write(`Bye {%= user.Name %}`)
+	_ = w.Check()
+	if reloads != 2 {
+		t.Errorf("reloads count mismatch: %d", reloads)
+	}
+	render("Bye John")
+}
+
+func TestWatcherPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dyntpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reload := make(chan string, 1)
+	w := NewWatcher(dir, "*.tpl", false, 10*time.Millisecond)
+	w.OnReload = func(id, _ string) {
+		reload <- id
+	}
+	if err = w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "poll.tpl"), []byte(`poll`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-reload:
+		if id != "poll" {
+			t.Errorf("reloaded tpl mismatch: %s", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("template wasn't reloaded")
+	}
+	w.Stop()
+
+	result, _ := Render("poll", NewCtx())
+	if !bytes.Equal(result, []byte(`poll`)) {
+		t.Errorf("polled tpl mismatch: %s", result)
+	}
+}
+
+func TestWatcherCallbackReenter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dyntpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "ok.tpl"), []byte(`ok`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "bad.tpl"), []byte(`{% if a && (b %}x{% endif %}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Callbacks may use the watcher, e.g. to alert and stop watching.
+	var reloads, fails int
+	w := NewWatcher(dir, "*.tpl", false, time.Hour)
+	w.OnReload = func(_, _ string) {
+		reloads++
+		if err := w.Check(); err != nil {
+			t.Error(err)
+		}
+	}
+	w.OnError = func(_ string, _ error) {
+		fails++
+		w.Stop()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := w.Check(); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback deadlocks the watcher")
+	}
+	if reloads != 1 || fails != 1 {
+		t.Errorf("callbacks count mismatch: %d %d", reloads, fails)
+	}
+}